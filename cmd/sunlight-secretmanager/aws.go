@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// awsBackend keeps seeds in AWS Secrets Manager, where each log's secret is
+// accessed with the log's role (see secretManagerConfig.role) in the
+// configured regions.
+type awsBackend struct {
+	smc     secretManagerConfig
+	clients *clientCache
+}
+
+// newAWSBackend returns a backend which accesses Secrets Manager with the
+// credentials from the environment, configured by smc.
+func newAWSBackend(ctx context.Context, smc secretManagerConfig) (*awsBackend, error) {
+	clients, err := newClientCache(ctx, smc.Regions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &awsBackend{smc: smc, clients: clients}, nil
+}
+
+func (b *awsBackend) store(lc logConfig) (seedStore, error) {
+	role, err := b.smc.role(lc)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAWSStore(b.clients.forRole(role), b.smc, lc, role.AccountID), nil
+}
+
+// fetchAll fetches the seeds of the given logs with fetchSeeds. Seeds can only
+// be fetched together if they're accessed with the same role.
+func (b *awsBackend) fetchAll(ctx context.Context, logs []logConfig) map[string]seedFetch {
+	fetched := make(map[string]seedFetch, len(logs))
+
+	idsByRole := make(map[roleConfig][]string)
+	for _, lc := range logs {
+		role, err := b.smc.role(lc)
+		if err != nil {
+			fetched[lc.SecretID] = seedFetch{Seed: nil, Version: seedVersion{ID: "", Source: "", Replica: false}, Err: err}
+
+			continue
+		}
+
+		idsByRole[role] = append(idsByRole[role], lc.SecretID)
+	}
+
+	for role, ids := range idsByRole {
+		maps.Copy(fetched, fetchSeeds(ctx, b.clients.forRole(role).Regions, ids, b.smc.Encoding))
+	}
+
+	return fetched
+}
+
+// awsStore holds the seed of a single log in Secrets Manager.
+type awsStore struct {
+	clients roleClients
+	meta    secretMetadata
+	account string
+	smc     secretManagerConfig
+}
+
+// newAWSStore returns the store for the log lc, whose secret is accessed with
+// clients, and belongs to the given account if it isn't empty.
+func newAWSStore(clients roleClients, smc secretManagerConfig, lc logConfig, account string) *awsStore {
+	return &awsStore{
+		clients: clients,
+		meta:    smc.secretMetadata(lc),
+		account: account,
+		smc:     smc,
+	}
+}
+
+// Get fetches the seed with fetchSeedFailover.
+func (s *awsStore) Get(ctx context.Context, id string) ([]byte, seedVersion, error) {
+	return fetchSeedFailover(ctx, s.clients.Regions, id, s.smc.Encoding)
+}
+
+// CreateIfAbsent stores seed with initSeed, in the primary region only. If the
+// log has an AccountID, it first makes sure that our credentials belong to
+// that account, since creating the secret in the wrong account would leave the
+// log's real secret empty.
+func (s *awsStore) CreateIfAbsent(ctx context.Context, id string, seed []byte) ([]byte, seedVersion, error) {
+	primary := s.clients.Regions[0]
+	version := regionVersion(s.clients.Regions, 0, "")
+
+	if s.account != "" {
+		err := checkAccount(ctx, s.clients.STS, s.account)
+		if err != nil {
+			return nil, version, fmt.Errorf("refusing to create seed: %w", err)
+		}
+	}
+
+	exists, err := secretExists(ctx, primary.Client, id)
+	if err != nil {
+		return nil, version, fmt.Errorf("in region %s: %w", primary.Region, err)
+	}
+
+	stored, versionID, err := initSeed(ctx, primary.Client, id, exists, s.meta, seed)
+	if err != nil {
+		return nil, version, fmt.Errorf("in region %s: %w", primary.Region, err)
+	}
+
+	version.ID = versionID
+
+	return stored, version, nil
+}
+
+// CheckPin checks the pinned version with checkPinnedVersion, in the region
+// which served the seed.
+func (s *awsStore) CheckPin(ctx context.Context, id string, version seedVersion) error {
+	_, err := checkPinnedVersion(ctx, s.client(version).Client, id, version.ID)
+
+	return err
+}
+
+// Pin checks the pinned version again, and pins the version with pinVersion if
+// no version is pinned yet. Replicas are read-only, so only the primary region
+// can pin the seed.
+func (s *awsStore) Pin(ctx context.Context, id string, version seedVersion) error {
+	client := s.client(version).Client
+
+	unpinned, err := checkPinnedVersion(ctx, client, id, version.ID)
+	if err != nil || unpinned == "" {
+		return err
+	}
+
+	if version.Replica {
+		log.Printf("Not pinning version %s of secret %q, since it was read from a replica", unpinned, id)
+
+		return nil
+	}
+
+	return pinVersion(ctx, client, id, unpinned)
+}
+
+// CheckSafety checks the secret with checkSecretSafety, in the region which
+// served the seed.
+func (s *awsStore) CheckSafety(ctx context.Context, id string, version seedVersion) error {
+	return checkSecretSafety(ctx, s.client(version).Client, id, s.smc)
+}
+
+// client returns the client of the region which served the given version.
+func (s *awsStore) client(version seedVersion) regionalClient {
+	for _, client := range s.clients.Regions {
+		if client.Region == version.Source {
+			return client
+		}
+	}
+
+	return s.clients.Regions[0]
+}
+
+// regionVersion returns the version with the given ID of a seed served by the
+// region with the given index in clients, of which the first is the primary
+// region.
+func regionVersion(clients []regionalClient, served int, id string) seedVersion {
+	return seedVersion{ID: id, Source: clients[served].Region, Replica: served != 0}
+}
+
+// secretExists reports whether the secret with the given ID exists, whether or
+// not it has a value.
+func secretExists(ctx context.Context, smClient SecretsManager, id string) (bool, error) {
+	_, err := smClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(id)})
+
+	err = classifyError(err)
+	if errors.Is(err, errSecretNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("describing secret %q: %w", id, err)
+	}
+
+	return true, nil
+}
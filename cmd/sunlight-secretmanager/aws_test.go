@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAWSStoreCreateIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		id          string
+		wantCreated bool
+	}{
+		{name: "absent secret", id: "absent", wantCreated: true},
+		{name: "empty secret", id: "terraform", wantCreated: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSM := &fakeSecretsManager{} //nolint:exhaustruct
+			clients := roleClients{Regions: []regionalClient{{Region: "us-west-2", Client: testSM}}, STS: nil}
+			store := newAWSStore(clients, secretManagerConfig{}, logConfig{Name: "test.tld/shard1"}, "") //nolint:exhaustruct
+
+			seed := newSeed()
+
+			got, version, err := store.CreateIfAbsent(t.Context(), tc.id, seed)
+			if err != nil {
+				t.Fatalf("CreateIfAbsent(%q) = %q, but want success", tc.id, err)
+			}
+
+			if !bytes.Equal(got, seed) {
+				t.Errorf("CreateIfAbsent(%q) = %x, but want %x", tc.id, got, seed)
+			}
+
+			if version.Source != "us-west-2" || version.Replica {
+				t.Errorf("CreateIfAbsent(%q) returned version %+v, but want one from the primary region", tc.id, version)
+			}
+
+			testSM.mu.Lock()
+			_, created := testSM.created[tc.id]
+			testSM.mu.Unlock()
+
+			if created != tc.wantCreated {
+				t.Errorf("CreateIfAbsent(%q) created the secret: %t, but want %t", tc.id, created, tc.wantCreated)
+			}
+		})
+	}
+}
+
+func TestAWSStoreClient(t *testing.T) {
+	t.Parallel()
+
+	primary := &fakeSecretsManager{} //nolint:exhaustruct
+	replica := &fakeSecretsManager{} //nolint:exhaustruct
+	store := newAWSStore(roleClients{
+		Regions: []regionalClient{{Region: "us-west-2", Client: primary}, {Region: "us-east-2", Client: replica}},
+		STS:     nil,
+	}, secretManagerConfig{}, logConfig{}, "") //nolint:exhaustruct
+
+	for _, tc := range []struct {
+		version seedVersion
+		want    SecretsManager
+	}{
+		{version: seedVersion{ID: "", Source: "us-west-2", Replica: false}, want: primary},
+		{version: seedVersion{ID: "", Source: "us-east-2", Replica: true}, want: replica},
+		{version: seedVersion{ID: "", Source: "", Replica: false}, want: primary},
+	} {
+		if got := store.client(tc.version); got.Client != tc.want {
+			t.Errorf("client(%+v) = the client for %s", tc.version, got.Region)
+		}
+	}
+}
+
+func TestAWSStorePin(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name       string
+		version    seedVersion
+		wantPinned string
+	}{
+		{name: "primary", version: seedVersion{ID: "v1", Source: "us-west-2", Replica: false}, wantPinned: "v1"},
+		{name: "replica", version: seedVersion{ID: "v1", Source: "us-east-2", Replica: true}, wantPinned: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			primary := &fakeSecretsManager{} //nolint:exhaustruct
+			replica := &fakeSecretsManager{} //nolint:exhaustruct
+			store := newAWSStore(roleClients{
+				Regions: []regionalClient{{Region: "us-west-2", Client: primary}, {Region: "us-east-2", Client: replica}},
+				STS:     nil,
+			}, secretManagerConfig{}, logConfig{}, "") //nolint:exhaustruct
+
+			err := store.CheckPin(t.Context(), "real", tc.version)
+			if err != nil {
+				t.Fatalf("CheckPin(real) = %q, but want success", err)
+			}
+
+			if len(primary.pinned) != 0 || len(replica.pinned) != 0 {
+				t.Fatalf("CheckPin(real) pinned a version, but want nothing pinned")
+			}
+
+			err = store.Pin(t.Context(), "real", tc.version)
+			if err != nil {
+				t.Fatalf("Pin(real) = %q, but want success", err)
+			}
+
+			if got := primary.pinned["real"]; got != tc.wantPinned {
+				t.Errorf("Pin(real) pinned version %q in the primary, but want %q", got, tc.wantPinned)
+			}
+
+			if len(replica.pinned) != 0 {
+				t.Errorf("Pin(real) pinned version %q in the replica, but want nothing pinned", replica.pinned["real"])
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// The backends in which seeds can be kept.
+const (
+	// backendAWS is AWS Secrets Manager. It is the default.
+	backendAWS = "aws"
+	// backendVault is a HashiCorp Vault KV v2 secrets engine.
+	backendVault = "vault"
+)
+
+// seedVersion describes the version of a secret from which a seed was read.
+type seedVersion struct {
+	// ID is the backend's ID of the version. It is empty if the secret has no
+	// value yet.
+	ID string
+	// Source is where the seed was read from, such as an AWS region, for log
+	// messages.
+	Source string
+	// Replica is set if the seed was read from a read-only replica, in which
+	// seeds can be neither created nor pinned.
+	Replica bool
+}
+
+// seedFetch is the outcome of fetching a single seed.
+type seedFetch struct {
+	// Seed is the seed, which is empty if the secret has no value yet.
+	Seed []byte
+	// Version is the version which held the seed.
+	Version seedVersion
+	// Err is the error fetching the seed, if any. It wraps errSecretNotFound
+	// if the secret doesn't exist.
+	Err error
+}
+
+// seedStore is the store which holds the seed of a single log. getOrCreateSeed
+// only talks to the store through this interface, so that the logic for
+// creating and checking seeds is the same whichever backend keeps them.
+type seedStore interface {
+	// Get returns the seed in the secret with the given ID, and the version
+	// which holds it. If the secret exists but has no value yet, the seed is
+	// empty. If the secret doesn't exist, the error wraps errSecretNotFound.
+	Get(ctx context.Context, id string) ([]byte, seedVersion, error)
+	// CreateIfAbsent stores seed as the first value of the secret with the
+	// given ID, creating the secret if need be. If another seed was stored
+	// first, it leaves that seed alone. Either way, it returns the seed which
+	// is actually stored, and its version. It must be safe to retry.
+	CreateIfAbsent(ctx context.Context, id string, seed []byte) ([]byte, seedVersion, error)
+	// CheckPin makes sure that the given version is the one which has held
+	// the seed since it was first used, or could be recorded as such. It never
+	// changes the secret.
+	CheckPin(ctx context.Context, id string, version seedVersion) error
+	// Pin checks the given version like CheckPin, and records it as the one
+	// which holds the seed if no version is recorded yet. Since the record is
+	// permanent, it must only be called once the seed has passed every check.
+	Pin(ctx context.Context, id string, version seedVersion) error
+	// CheckSafety checks the secret with the given ID for settings which put
+	// the seed at risk, such as a pending deletion.
+	CheckSafety(ctx context.Context, id string, version seedVersion) error
+}
+
+// seedBackend is a place where the seeds of logs are kept.
+type seedBackend interface {
+	// store returns the store which holds the seed of the given log.
+	store(lc logConfig) (seedStore, error)
+	// fetchAll fetches the seeds of all the given logs, keyed by secret ID.
+	fetchAll(ctx context.Context, logs []logConfig) map[string]seedFetch
+}
+
+// checkBackend returns an error if the Backend setting is not one we know.
+func (smc secretManagerConfig) checkBackend() error {
+	switch smc.Backend {
+	case "", backendAWS, backendVault:
+		return nil
+	default:
+		return fmt.Errorf("unknown backend %q: must be %s or %s", smc.Backend, backendAWS, backendVault)
+	}
+}
+
+// newBackend returns the backend configured by smc.
+func newBackend(ctx context.Context, smc secretManagerConfig) (seedBackend, error) {
+	switch smc.Backend {
+	case "", backendAWS:
+		return newAWSBackend(ctx, smc)
+	case backendVault:
+		return newVaultBackend(ctx, smc)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", smc.Backend)
+	}
+}
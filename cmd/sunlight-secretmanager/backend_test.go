@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a seedStore which keeps seeds in memory, to test the parts of
+// getOrCreateSeed which don't depend on any particular backend.
+type memoryStore struct {
+	mu      sync.Mutex
+	seeds   map[string][]byte
+	pinned  map[string]string
+	creates int
+}
+
+func (s *memoryStore) Get(_ context.Context, id string) ([]byte, seedVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seed, ok := s.seeds[id]
+	if !ok {
+		return nil, seedVersion{ID: "", Source: "memory", Replica: false}, fmt.Errorf("secret %q: %w", id, errSecretNotFound)
+	}
+
+	return seed, seedVersion{ID: "1", Source: "memory", Replica: false}, nil
+}
+
+func (s *memoryStore) CreateIfAbsent(_ context.Context, id string, seed []byte) ([]byte, seedVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.creates++
+
+	if _, ok := s.seeds[id]; !ok {
+		s.seeds[id] = seed
+	}
+
+	return s.seeds[id], seedVersion{ID: "1", Source: "memory", Replica: false}, nil
+}
+
+func (s *memoryStore) CheckPin(_ context.Context, id string, version seedVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pinned, ok := s.pinned[id]; ok && pinned != version.ID {
+		return fmt.Errorf("secret %q has version %s, but version %s is pinned", id, version.ID, pinned)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Pin(ctx context.Context, id string, version seedVersion) error {
+	err := s.CheckPin(ctx, id, version)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pinned[id] = version.ID
+
+	return nil
+}
+
+func (s *memoryStore) CheckSafety(context.Context, string, seedVersion) error {
+	return nil
+}
+
+func TestCheckBackend(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		backend string
+		wantErr bool
+	}{
+		{backend: "", wantErr: false},
+		{backend: "aws", wantErr: false},
+		{backend: "gcp", wantErr: true},
+	} {
+		t.Run(tc.backend, func(t *testing.T) {
+			t.Parallel()
+
+			err := secretManagerConfig{Backend: tc.backend}.checkBackend() //nolint:exhaustruct
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkBackend() = %v, but want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetOrCreateSeedStore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.June, 15, 23, 59, 59, 0, time.UTC)
+	today := now.Format(time.DateOnly)
+	existing := []byte("an existing seed")
+
+	for _, tc := range []struct {
+		name        string
+		id          string
+		fetched     seedFetch
+		wantSeed    []byte
+		wantCreates int
+		wantErr     string
+	}{
+		{
+			name:        "existing seed",
+			id:          "existing",
+			fetched:     seedFetch{Seed: existing, Version: seedVersion{ID: "1", Source: "memory", Replica: false}, Err: nil},
+			wantSeed:    existing,
+			wantCreates: 0,
+			wantErr:     "",
+		},
+		{
+			name:        "absent secret",
+			id:          "absent",
+			fetched:     seedFetch{Seed: nil, Version: seedVersion{ID: "", Source: "memory", Replica: false}, Err: errSecretNotFound},
+			wantSeed:    nil,
+			wantCreates: 1,
+			wantErr:     "",
+		},
+		{
+			name:        "empty replica",
+			id:          "absent",
+			fetched:     seedFetch{Seed: nil, Version: seedVersion{ID: "", Source: "replica", Replica: true}, Err: nil},
+			wantSeed:    nil,
+			wantCreates: 0,
+			wantErr:     "log has empty seed in replica replica, but seeds can only be created in the primary",
+		},
+		{
+			name:        "unpinned version",
+			id:          "existing",
+			fetched:     seedFetch{Seed: existing, Version: seedVersion{ID: "2", Source: "memory", Replica: false}, Err: nil},
+			wantSeed:    nil,
+			wantCreates: 0,
+			wantErr:     `error checking seed version: secret "existing" has version 2, but version 1 is pinned`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &memoryStore{ //nolint:exhaustruct
+				seeds:  map[string][]byte{"existing": existing},
+				pinned: map[string]string{"existing": "1"},
+			}
+			logConf := logConfig{Name: "test.tld/shard1", Inception: today} //nolint:exhaustruct
+			conf := &config{Logs: []logConfig{logConf}}                     //nolint:exhaustruct
+
+			got, _, err := getOrCreateSeed(t.Context(), tc.id, logConf, conf, store, tc.fetched, now)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("getOrCreateSeed(%q) = %v, but want error %q", tc.id, err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("getOrCreateSeed(%q) = %q, but want success", tc.id, err)
+			} else if tc.wantSeed != nil && !bytes.Equal(got, tc.wantSeed) {
+				t.Errorf("getOrCreateSeed(%q) = %q, but want %q", tc.id, got, tc.wantSeed)
+			} else if tc.wantSeed == nil && !bytes.Equal(got, store.seeds[tc.id]) {
+				t.Errorf("getOrCreateSeed(%q) = %x, but the stored seed is %x", tc.id, got, store.seeds[tc.id])
+			}
+
+			if store.creates != tc.wantCreates {
+				t.Errorf("getOrCreateSeed(%q) called CreateIfAbsent %d times, but want %d", tc.id, store.creates, tc.wantCreates)
+			}
+
+			// Pinning is left to the caller, once the seed has passed every
+			// other check.
+			if pinned, ok := store.pinned[tc.id]; ok && tc.id != "existing" {
+				t.Errorf("getOrCreateSeed(%q) pinned version %s, but want nothing pinned", tc.id, pinned)
+			}
+		})
+	}
+}
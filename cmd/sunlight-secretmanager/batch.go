@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// batchSize is the largest number of secrets which BatchGetSecretValue
+	// accepts in a single request.
+	batchSize = 20
+	// fetchConcurrency is the number of seeds which fetchSeeds fetches at
+	// once when it can't use BatchGetSecretValue.
+	fetchConcurrency = 8
+)
+
+// fetchSeeds fetches the seeds with the given IDs, decoded with the given
+// encoding, and returns the outcome for each of them. It fetches them from the
+// primary region with BatchGetSecretValue, batchSize at a time. Seeds which the
+// batch doesn't return, because they don't exist or because of transient
+// errors, are then fetched one at a time with fetchSeedFailover, as are all of
+// them if BatchGetSecretValue fails altogether, such as when it isn't
+// permitted. No more than fetchConcurrency of those requests are made at once.
+func fetchSeeds(ctx context.Context, clients []regionalClient, ids []string, encoding string) map[string]seedFetch {
+	fetched := make(map[string]seedFetch, len(ids))
+
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+
+		res, err := batchFetchSeeds(ctx, clients[0], batch, encoding)
+		if err != nil {
+			log.Printf("Fetching %d seeds at once failed, fetching them one at a time: %v", len(batch), err)
+
+			break
+		}
+
+		for id, f := range res {
+			// fetchSeedFailover tells secrets without a value apart from
+			// missing ones, and tries other regions after transient errors.
+			if errors.Is(f.Err, errSecretNotFound) || errors.Is(f.Err, errTransient) {
+				continue
+			}
+
+			fetched[id] = f
+		}
+	}
+
+	var remaining []string
+	for _, id := range ids {
+		if _, ok := fetched[id]; !ok {
+			remaining = append(remaining, id)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan string)
+
+	for range min(fetchConcurrency, len(remaining)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for id := range work {
+				var version seedVersion
+				seed, err := retryTransient(ctx, transientRetryDelays, func() ([]byte, error) {
+					seed, v, err := fetchSeedFailover(ctx, clients, id, encoding)
+					version = v
+
+					return seed, err
+				})
+
+				mu.Lock()
+				fetched[id] = seedFetch{Seed: seed, Version: version, Err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, id := range remaining {
+		work <- id
+	}
+
+	close(work)
+	wg.Wait()
+
+	return fetched
+}
+
+// batchFetchSeeds fetches the seeds with the given IDs, of which there may be
+// no more than batchSize, with a single BatchGetSecretValue request to the
+// primary region. It returns an error only if the request as a whole fails.
+// Secrets which couldn't be fetched are returned with errors of their own,
+// classified by classifyError, and secrets which are missing from the response
+// aren't returned at all.
+func batchFetchSeeds(ctx context.Context, primary regionalClient, ids []string, encoding string) (map[string]seedFetch, error) {
+	req := &secretsmanager.BatchGetSecretValueInput{
+		Filters:      nil,
+		MaxResults:   nil,
+		NextToken:    nil,
+		SecretIdList: ids,
+	}
+
+	res, err := primary.Client.BatchGetSecretValue(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("batch retrieving secrets: %w", classifyError(err))
+	}
+
+	// The response names secrets by name and ARN, rather than by the IDs in
+	// the request, which may be either.
+	requested := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		requested[id] = true
+	}
+
+	fetched := make(map[string]seedFetch, len(ids))
+	version := seedVersion{ID: "", Source: primary.Region, Replica: false}
+
+	for _, value := range res.SecretValues {
+		for _, id := range []string{aws.ToString(value.Name), aws.ToString(value.ARN)} {
+			if requested[id] {
+				seed, err := decodeSeed(id, encoding, value.SecretBinary, value.SecretString)
+				valueVersion := seedVersion{ID: aws.ToString(value.VersionId), Source: primary.Region, Replica: false}
+				fetched[id] = seedFetch{Seed: seed, Version: valueVersion, Err: err}
+			}
+		}
+	}
+
+	for _, apiErr := range res.Errors {
+		id := aws.ToString(apiErr.SecretId)
+		if requested[id] {
+			fetched[id] = seedFetch{Seed: nil, Version: version, Err: fmt.Errorf("retrieving secret %q: %w", id, classifyError(batchError(apiErr)))}
+		}
+	}
+
+	return fetched, nil
+}
+
+// batchError turns an error which BatchGetSecretValue reports for a single
+// secret into the error which GetSecretValue would have returned for it, so
+// that classifyError can classify it.
+func batchError(apiErr types.APIErrorType) error {
+	msg := apiErr.Message
+
+	switch code := aws.ToString(apiErr.ErrorCode); code {
+	case "ResourceNotFoundException":
+		return &types.ResourceNotFoundException{Message: msg} //nolint:exhaustruct
+	case "InvalidRequestException":
+		return &types.InvalidRequestException{Message: msg} //nolint:exhaustruct
+	case "DecryptionFailure":
+		return &types.DecryptionFailure{Message: msg} //nolint:exhaustruct
+	case "InternalServiceError":
+		return &types.InternalServiceError{Message: msg} //nolint:exhaustruct
+	default:
+		return &smithy.GenericAPIError{Code: code, Message: aws.ToString(msg), Fault: smithy.FaultUnknown}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+func TestFetchSeeds(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		batchDenied bool
+		wantBatches int
+	}{
+		{name: "batch", batchDenied: false, wantBatches: 2},
+		{name: "batch denied", batchDenied: true, wantBatches: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSM := &fakeSecretsManager{batchDenied: tc.batchDenied} //nolint:exhaustruct
+			clients := []regionalClient{{Region: "us-west-2", Client: testSM}}
+			primary := seedVersion{ID: "", Source: "us-west-2", Replica: false}
+
+			want := map[string]seedFetch{
+				"real":      {Seed: []byte("hello world"), Version: seedVersion{ID: "v1", Source: "us-west-2", Replica: false}, Err: nil},
+				"terraform": {Seed: nil, Version: primary, Err: nil},
+				"absent":    {Seed: nil, Version: primary, Err: errSecretNotFound},
+				"denied":    {Seed: nil, Version: primary, Err: errAccessDenied},
+				"deleted":   {Seed: nil, Version: primary, Err: errSecretScheduledForDeletion},
+			}
+
+			// Enough seeds to take more than one batch.
+			for i := range batchSize {
+				id := fmt.Sprintf("shard%d", i)
+				seed := []byte(id)
+				testSM.store(id, seed)
+				want[id] = seedFetch{Seed: seed, Version: seedVersion{ID: seedRequestToken(id), Source: "us-west-2", Replica: false}, Err: nil}
+			}
+
+			ids := make([]string, 0, len(want))
+			for id := range want {
+				ids = append(ids, id)
+			}
+
+			got := fetchSeeds(t.Context(), clients, ids, "")
+
+			for id, w := range want {
+				g, ok := got[id]
+				switch {
+				case !ok:
+					t.Errorf("fetchSeeds() returned nothing for %q", id)
+				case w.Err != nil && !errors.Is(g.Err, w.Err):
+					t.Errorf("fetchSeeds() returned error %v for %q, but want error wrapping %q", g.Err, id, w.Err)
+				case w.Err == nil && g.Err != nil:
+					t.Errorf("fetchSeeds() returned error %q for %q, but want success", g.Err, id)
+				case !bytes.Equal(g.Seed, w.Seed):
+					t.Errorf("fetchSeeds() returned seed %q for %q, but want %q", g.Seed, id, w.Seed)
+				case g.Version != w.Version:
+					t.Errorf("fetchSeeds() returned version %+v for %q, but want %+v", g.Version, id, w.Version)
+				}
+			}
+
+			if testSM.batches != tc.wantBatches {
+				t.Errorf("fetchSeeds() made %d BatchGetSecretValue requests, but want %d", testSM.batches, tc.wantBatches)
+			}
+		})
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		code string
+		msg  string
+		want error
+	}{
+		{code: "ResourceNotFoundException", msg: "Secrets Manager can't find the specified secret.", want: errSecretNotFound},
+		{code: "InvalidRequestException", msg: "You can't perform this operation on the secret because it was marked for deletion.", want: errSecretScheduledForDeletion},
+		{code: "DecryptionFailure", msg: "Secrets Manager can't decrypt the protected secret text using the provided KMS key.", want: errKMSDecrypt},
+		{code: "InternalServiceError", msg: "An error occurred on the server side.", want: errTransient},
+		{code: "AccessDeniedException", msg: "not authorized", want: errAccessDenied},
+	} {
+		t.Run(tc.code, func(t *testing.T) {
+			t.Parallel()
+
+			apiErr := types.APIErrorType{ErrorCode: aws.String(tc.code), Message: aws.String(tc.msg), SecretId: aws.String("shard1")} //nolint:exhaustruct
+
+			got := classifyError(batchError(apiErr))
+			if !errors.Is(got, tc.want) {
+				t.Errorf("classifyError(batchError(%s)) = %q, but want it to wrap %q", tc.code, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// rfc6962SignatureType is the signed-note signature type of the
+// RFC6962NoteSignature defined by c2sp.org/static-ct-api, with which Sunlight
+// signs its checkpoints using the log's ECDSA key.
+const rfc6962SignatureType = 0x05
+
+// checkpointPath returns the path at which to find the log's latest checkpoint:
+// the configured Checkpoint path if any, otherwise the checkpoint file in the
+// log's LocalDirectory if any, otherwise the empty string.
+func (lc logConfig) checkpointPath() string {
+	if lc.Checkpoint != "" {
+		return lc.Checkpoint
+	}
+
+	if lc.LocalDirectory != "" {
+		return filepath.Join(lc.LocalDirectory, "checkpoint")
+	}
+
+	return ""
+}
+
+// verifyCheckpoint checks that the checkpoint at path was signed for the named
+// log by the ECDSA key in keys. This proves that a seed is the one which has
+// been signing for the log. It returns false (and no error) if there is no
+// checkpoint at path, which is the case for logs that have not yet started.
+func verifyCheckpoint(path string, name string, keys *logKeys) (bool, error) {
+	checkpoint, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("reading checkpoint %q: %w", path, err)
+	}
+
+	verifier, err := newRFC6962Verifier(name, keys)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := note.Open(checkpoint, note.VerifierList(verifier))
+	if err != nil {
+		return false, fmt.Errorf("verifying checkpoint %q: %w", path, err)
+	}
+
+	origin, _, _ := strings.Cut(n.Text, "\n")
+	if origin != name {
+		return false, fmt.Errorf("checkpoint %q has origin %q, but want %q", path, origin, name)
+	}
+
+	return true, nil
+}
+
+// rfc6962Verifier is a note.Verifier for the RFC6962NoteSignature type defined
+// by c2sp.org/static-ct-api. Such signatures are RFC 6962 tree head signatures
+// over the tree size and root hash in the checkpoint.
+type rfc6962Verifier struct {
+	name    string
+	keyHash uint32
+	key     *ecdsa.PublicKey
+}
+
+var _ note.Verifier = (*rfc6962Verifier)(nil)
+
+func newRFC6962Verifier(name string, keys *logKeys) (*rfc6962Verifier, error) {
+	if !isValidNoteName(name) {
+		return nil, fmt.Errorf("invalid checkpoint origin %q", name)
+	}
+
+	// The key ID is the first four bytes of SHA-256(name || "\n" || 0x05 ||
+	// log ID), where the log ID is the SHA-256 hash of the log's SPKI.
+	logID := keys.logID()
+	h := sha256.New()
+	h.Write([]byte(name + "\n"))
+	h.Write([]byte{rfc6962SignatureType})
+	h.Write(logID[:])
+
+	return &rfc6962Verifier{
+		name:    name,
+		keyHash: binary.BigEndian.Uint32(h.Sum(nil)),
+		key:     keys.PublicKey,
+	}, nil
+}
+
+func (v *rfc6962Verifier) Name() string {
+	return v.name
+}
+
+func (v *rfc6962Verifier) KeyHash() uint32 {
+	return v.keyHash
+}
+
+// Verify checks an RFC6962NoteSignature, which is a big-endian uint64
+// timestamp followed by an RFC 5246 DigitallySigned struct.
+func (v *rfc6962Verifier) Verify(msg []byte, sig []byte) bool {
+	size, rootHash, ok := parseCheckpointBody(msg)
+	if !ok {
+		return false
+	}
+
+	//nolint:mnd // These are lengths of fixed-size fields in the signature.
+	if len(sig) < 8+4 {
+		return false
+	}
+
+	timestamp, sig := sig[:8], sig[8:]
+
+	// The hash algorithm must be SHA-256 (4) and the signature algorithm must
+	// be ECDSA (3).
+	if sig[0] != 4 || sig[1] != 3 {
+		return false
+	}
+
+	sigLen := int(binary.BigEndian.Uint16(sig[2:4]))
+	if len(sig) != 4+sigLen {
+		return false
+	}
+
+	// The signed data is an RFC 6962 TreeHeadSignature: version v1 (0),
+	// signature type tree_hash (1), timestamp, tree size and root hash.
+	signed := make([]byte, 0, 2+8+8+sha256.Size)
+	signed = append(signed, 0, 1)
+	signed = append(signed, timestamp...)
+	signed = binary.BigEndian.AppendUint64(signed, size)
+	signed = append(signed, rootHash...)
+	digest := sha256.Sum256(signed)
+
+	return ecdsa.VerifyASN1(v.key, digest[:], sig[4:])
+}
+
+// parseCheckpointBody extracts the tree size and root hash from the body of a
+// c2sp.org/tlog-checkpoint, which is an origin line, a decimal tree size line,
+// a base64 root hash line, and optional extension lines.
+func parseCheckpointBody(msg []byte) (uint64, []byte, bool) {
+	lines := bytes.SplitN(msg, []byte("\n"), 4) //nolint:mnd
+	if len(lines) < 4 {
+		return 0, nil, false
+	}
+
+	size, err := strconv.ParseUint(string(lines[1]), 10, 64)
+	if err != nil {
+		return 0, nil, false
+	}
+
+	rootHash, err := base64.StdEncoding.DecodeString(string(lines[2]))
+	if err != nil || len(rootHash) != sha256.Size {
+		return 0, nil, false
+	}
+
+	return size, rootHash, true
+}
+
+// isValidNoteName reports whether name is valid as a signed-note key name:
+// non-empty, with no Unicode spaces and no plus signs.
+func isValidNoteName(name string) bool {
+	return name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return r == '+' || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointPath(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		conf logConfig
+		want string
+	}{
+		{
+			name: "neither",
+			conf: logConfig{}, //nolint:exhaustruct
+			want: "",
+		},
+		{
+			name: "local directory",
+			conf: logConfig{LocalDirectory: "/var/db/sunlight/shard1/"}, //nolint:exhaustruct
+			want: "/var/db/sunlight/shard1/checkpoint",
+		},
+		{
+			name: "explicit checkpoint",
+			conf: logConfig{LocalDirectory: "/var/db/sunlight/shard1/", Checkpoint: "/tmp/checkpoint"}, //nolint:exhaustruct
+			want: "/tmp/checkpoint",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tc.conf.checkpointPath()
+			if got != tc.want {
+				t.Errorf("checkpointPath() = %q, but want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCheckpoint(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	// testdata/checkpoint was signed for test.tld/shard1 with filippo.io/keygen
+	// by the key derived from the all-zeros seed. Make a copy with a different
+	// tree size.
+	checkpoint, err := os.ReadFile(filepath.Join("testdata", "checkpoint"))
+	if err != nil {
+		t.Fatalf("failed to read test checkpoint: %s", err)
+	}
+
+	tampered := filepath.Join(tempDir, "tampered")
+	err = os.WriteFile(tampered, bytes.Replace(checkpoint, []byte("\n1234\n"), []byte("\n1235\n"), 1), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write tampered checkpoint: %s", err)
+	}
+
+	sequential := make([]byte, seedLen)
+	for i := range sequential {
+		sequential[i] = byte(i)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		path         string
+		logName      string
+		seed         []byte
+		wantVerified bool
+		wantErr      string
+	}{
+		{
+			name:         "no checkpoint",
+			path:         filepath.Join(tempDir, "missing"),
+			logName:      "test.tld/shard1",
+			seed:         make([]byte, seedLen),
+			wantVerified: false,
+			wantErr:      "",
+		},
+		{
+			name:         "unreadable checkpoint",
+			path:         tempDir,
+			logName:      "test.tld/shard1",
+			seed:         make([]byte, seedLen),
+			wantVerified: false,
+			wantErr:      "reading checkpoint",
+		},
+		{
+			name:         "wrong seed",
+			path:         filepath.Join("testdata", "checkpoint"),
+			logName:      "test.tld/shard1",
+			seed:         sequential,
+			wantVerified: false,
+			wantErr:      "verifying checkpoint",
+		},
+		{
+			name:         "wrong log",
+			path:         filepath.Join("testdata", "checkpoint"),
+			logName:      "test.tld/shard2",
+			seed:         make([]byte, seedLen),
+			wantVerified: false,
+			wantErr:      "verifying checkpoint",
+		},
+		{
+			name:         "tampered checkpoint",
+			path:         tampered,
+			logName:      "test.tld/shard1",
+			seed:         make([]byte, seedLen),
+			wantVerified: false,
+			wantErr:      "verifying checkpoint",
+		},
+		{
+			name:         "happy path",
+			path:         filepath.Join("testdata", "checkpoint"),
+			logName:      "test.tld/shard1",
+			seed:         make([]byte, seedLen),
+			wantVerified: true,
+			wantErr:      "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := deriveKeys(tc.seed)
+			if err != nil {
+				t.Fatalf("deriveKeys() = %#v, but want no error", err)
+			}
+
+			verified, err := verifyCheckpoint(tc.path, tc.logName, keys)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("verifyCheckpoint(%q) = %t, but want error %q", tc.path, verified, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("verifyCheckpoint(%q) = %q, but want error %q", tc.path, err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("verifyCheckpoint(%q) = %q, but want success", tc.path, err)
+				} else if verified != tc.wantVerified {
+					t.Errorf("verifyCheckpoint(%q) = %t, but want %t", tc.path, verified, tc.wantVerified)
+				}
+			}
+		})
+	}
+}
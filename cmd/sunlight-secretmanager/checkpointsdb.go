@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+
+	// Register the "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+// checkpointsDBHasLog reports whether Sunlight's checkpoints database at path
+// already has a checkpoint for the given log. Sunlight records each log's
+// latest checkpoint in this database (keyed by log ID) as soon as the log is
+// created, so a row for the log means that it has been initialized, and that
+// its seed must not be replaced. A missing database has no checkpoints.
+func checkpointsDBHasLog(ctx context.Context, path string, lc logConfig) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("opening checkpoints database %q: %w", path, err)
+	}
+
+	dsn := (&url.URL{Scheme: "file", OmitHost: true, Path: path, RawQuery: "mode=ro"}).String() //nolint:exhaustruct
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return false, fmt.Errorf("opening checkpoints database %q: %w", path, err)
+	}
+	defer db.Close()
+
+	var count int
+	if lc.LogID != "" {
+		var logID []byte
+
+		logID, err = base64.StdEncoding.DecodeString(lc.LogID)
+		if err != nil {
+			return false, fmt.Errorf("decoding log ID %q: %w", lc.LogID, err)
+		}
+
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM checkpoints WHERE logID = ?", logID).Scan(&count)
+	} else {
+		// Without a configured log ID, we can't look the log up by it, because
+		// we don't know its key, so we look for a checkpoint whose origin line
+		// is the log's name instead.
+		err = db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM checkpoints WHERE substr(body, 1, length(?1)) = ?1",
+			lc.Name+"\n",
+		).Scan(&count)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("querying checkpoints database %q: %w", path, err)
+	}
+
+	return count > 0, nil
+}
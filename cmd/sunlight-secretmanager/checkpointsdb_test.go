@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createCheckpointsDB creates a checkpoints database like Sunlight's in a
+// temporary directory, with a checkpoint for each of the named logs. Each log's
+// ID is the SHA-256 hash of its name, as returned by testLogID.
+func createCheckpointsDB(t *testing.T, names ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("failed to create checkpoints database: %s", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE checkpoints (logID BLOB PRIMARY KEY, body TEXT)")
+	if err != nil {
+		t.Fatalf("failed to create checkpoints table: %s", err)
+	}
+
+	for _, name := range names {
+		_, err = db.ExecContext(t.Context(), "INSERT INTO checkpoints (logID, body) VALUES (?, ?)",
+			testLogIDBytes(name), name+"\n0\n47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=\n")
+		if err != nil {
+			t.Fatalf("failed to insert checkpoint: %s", err)
+		}
+	}
+
+	return path
+}
+
+// testLogIDBytes returns the log ID which createCheckpointsDB gives the named
+// log.
+func testLogIDBytes(name string) []byte {
+	h := sha256.Sum256([]byte(name))
+
+	return h[:]
+}
+
+// testLogID returns the base64 encoding of testLogIDBytes(name).
+func testLogID(name string) string {
+	return base64.StdEncoding.EncodeToString(testLogIDBytes(name))
+}
+
+func TestCheckpointsDBHasLog(t *testing.T) {
+	t.Parallel()
+
+	path := createCheckpointsDB(t, "test.tld/shard1", "test.tld/shard10")
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		logName string
+		logID   string
+		want    bool
+		wantErr string
+	}{
+		{
+			name:    "missing database",
+			path:    filepath.Join(t.TempDir(), "missing.db"),
+			logName: "test.tld/shard1",
+			logID:   "",
+			want:    false,
+			wantErr: "",
+		},
+		{
+			name:    "not a database",
+			path:    filepath.Join("testdata", "happy.yaml"),
+			logName: "test.tld/shard1",
+			logID:   "",
+			want:    false,
+			wantErr: "querying checkpoints database",
+		},
+		{
+			name:    "known log",
+			path:    path,
+			logName: "test.tld/shard1",
+			logID:   "",
+			want:    true,
+			wantErr: "",
+		},
+		{
+			name:    "unknown log",
+			path:    path,
+			logName: "test.tld/shard2",
+			logID:   "",
+			want:    false,
+			wantErr: "",
+		},
+		{
+			name:    "prefix of known log",
+			path:    path,
+			logName: "test.tld/shard",
+			logID:   "",
+			want:    false,
+			wantErr: "",
+		},
+		{
+			name:    "known log ID",
+			path:    path,
+			logName: "test.tld/renamed",
+			logID:   testLogID("test.tld/shard1"),
+			want:    true,
+			wantErr: "",
+		},
+		{
+			name:    "unknown log ID of known name",
+			path:    path,
+			logName: "test.tld/shard1",
+			logID:   testLogID("test.tld/shard2"),
+			want:    false,
+			wantErr: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lc := logConfig{Name: tc.logName, LogID: tc.logID} //nolint:exhaustruct
+
+			got, err := checkpointsDBHasLog(t.Context(), tc.path, lc)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("checkpointsDBHasLog(%q) = %t, but want error %q", tc.logName, got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("checkpointsDBHasLog(%q) = %q, but want error %q", tc.logName, err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("checkpointsDBHasLog(%q) = %q, but want success", tc.logName, err)
+				} else if got != tc.want {
+					t.Errorf("checkpointsDBHasLog(%q) = %t, but want %t", tc.logName, got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,7 +16,72 @@ import (
 // config is a subset of Sunlight's Config. We use it to load config information
 // about each log.
 type config struct {
-	Logs []logConfig
+	// Checkpoints is the path to the SQLite database in which Sunlight records
+	// the latest checkpoint of each log. We use it to detect logs which have
+	// already been initialized.
+	Checkpoints string
+	// SecretManager holds settings for sunlight-secretmanager itself. It is
+	// not part of Sunlight's Config.
+	SecretManager secretManagerConfig
+	Logs          []logConfig
+}
+
+// secretManagerConfig holds the settings which apply to all logs.
+type secretManagerConfig struct {
+	// Backend is where seeds are kept: "aws" (the default) for AWS Secrets
+	// Manager, or "vault" for a HashiCorp Vault KV v2 secrets engine.
+	Backend string
+	// SecretName is a text/template which is executed against each log's
+	// logConfig to produce the ID of the secret in which its seed is stored,
+	// such as "sunlight/{{.Env}}/{{.ShortName}}/seed". It is optional. If it
+	// is not set, the base name of each log's Secret path is used instead.
+	SecretName string
+	// SeedCreation is the policy which decides when a new seed may be created
+	// for a log whose secret is empty: "inception-window" (the default),
+	// "before-inception" or "never".
+	SeedCreation string
+	// InceptionWindowDays is the number of days before a log's Inception date
+	// from which the "inception-window" policy allows a seed to be created.
+	// The default of zero only allows it on the Inception date itself.
+	InceptionWindowDays int
+	// AllowedPrincipals are the IAM principals, such as
+	// "arn:aws:iam::123456789012:role/terraform", which secrets' resource
+	// policies may allow to change or delete them.
+	AllowedPrincipals []string
+	// LaxPolicy is what to do with secrets whose resource policies allow other
+	// principals to change or delete them: "warn" (the default) or "fail".
+	LaxPolicy string
+	// KmsKeyID is the ID, ARN or alias of the KMS key with which created
+	// secrets are encrypted. It is optional, and defaults to the AWS managed
+	// key.
+	KmsKeyID string
+	// Description is the description of created secrets. It is optional.
+	Description string
+	// Tags are extra tags to put on created secrets, in addition to the log's
+	// name, short name, inception date and log ID.
+	Tags map[string]string
+	// ReplicaRegions are the regions to which created secrets are replicated.
+	// They are optional.
+	ReplicaRegions []replicaRegionConfig
+	// Regions are the regions from which seeds are read, in order of
+	// preference. The first is the primary region, the only one in which
+	// seeds are created. The others are only read from when the regions
+	// before them fail. They are optional, and default to the region
+	// configured in the environment.
+	Regions []regionConfig
+	// Encoding is the encoding with which seeds are stored in secrets:
+	// "binary" (the default) in SecretBinary, or "base64" or "hex" in
+	// SecretString.
+	Encoding string
+	// MinSeedLength is the minimum length of the seeds we write, in bytes. It
+	// is optional. By default, seeds must be exactly 32 bytes long, which is
+	// how long the seeds we create are.
+	MinSeedLength int
+	// Environments are the roles with which the secrets of the logs in each
+	// environment are accessed, by Env. They are optional.
+	Environments map[string]roleConfig
+	// Vault configures the Vault backend.
+	Vault vaultConfig
 }
 
 // logConfig is a subset of Sunlight's LogConfig. We use it to load just the
@@ -19,16 +90,244 @@ type logConfig struct {
 	// Name is the unique human-readable identifier of the log. We use it for
 	// logging purposes.
 	Name string
-	// Inception is the date at which the log will begin functioning. If the
-	// Inception date is in the future, and the Secret retrieved from AWS is
-	// empty, then sunlight-secretmanager will create a new secret. If the
-	// Inception date is in the past, then sunlight-secretmanager assumes that a
-	// previous run should have created a secret, and an empty secret will be
-	// treated as an error.
+	// ShortName is the short human-readable name of the log, which Sunlight
+	// uses e.g. in metrics.
+	ShortName string
+	// Inception is the date, in UTC, at which the log will begin functioning.
+	// If the Secret retrieved from AWS is empty, then sunlight-secretmanager
+	// will create a new secret if the Inception date is close enough according
+	// to the SeedCreation policy. If the Inception date is in the past, then
+	// sunlight-secretmanager assumes that a previous run should have created a
+	// secret, and an empty secret will be treated as an error.
 	Inception string
 	// Secret is the path to the file where the sunlight instance expects to
 	// find this log's secret seed. We write the seed to this path.
 	Secret string
+	// PublicKey is the path to the file where the sunlight instance expects to
+	// find this log's PEM-encoded public key. We write the public key derived
+	// from the seed to this path. It is optional: if it is not set, we write
+	// the public key next to the seed instead (see publicKeyPath).
+	PublicKey string
+	// SubmissionPrefix is the full URL of the c2sp.org/static-ct-api submission
+	// prefix of the log. We use it to find the log in CT log lists.
+	SubmissionPrefix string
+	// MonitoringPrefix is the full URL of the c2sp.org/static-ct-api monitoring
+	// prefix of the log. We use it to find the log in CT log lists.
+	MonitoringPrefix string
+	// NotAfterStart is the start of the validity range of certificates
+	// accepted by this log instance, as an RFC 3339 timestamp. It becomes the
+	// start of the log's temporal interval in CT log lists.
+	NotAfterStart string
+	// NotAfterLimit is the end of the validity range (not included) of
+	// certificates accepted by this log instance, as an RFC 3339 timestamp. It
+	// becomes the end of the log's temporal interval in CT log lists.
+	NotAfterLimit string
+	// LocalDirectory is the path to a local directory where Sunlight stores
+	// the log's data, if it uses local rather than S3 storage. We look for the
+	// log's latest checkpoint in it.
+	LocalDirectory string
+	// LogID is the base64-encoded SHA-256 hash of the log's public key, as it
+	// appears in CT log lists. It is optional, and is not part of Sunlight's
+	// LogConfig. If it is set, sunlight-secretmanager refuses to write a seed
+	// from which a different log ID is derived.
+	LogID string
+	// Description is the log's description in CT log lists, such as
+	// "Example 'Shard1'". It is optional, and is not part of Sunlight's
+	// LogConfig. We use it to find the log in CT log lists.
+	Description string
+	// Checkpoint is the path to a copy of the log's latest checkpoint. It is
+	// optional, and is not part of Sunlight's LogConfig. If it is not set, we
+	// look for the checkpoint in LocalDirectory instead.
+	Checkpoint string
+	// Env is the name of the environment the log belongs to, such as
+	// "staging". It is optional, and is not part of Sunlight's LogConfig. It
+	// is used by SecretName templates and to find the log's role in
+	// Environments.
+	Env string
+	// RoleARN is the ARN of the IAM role with which the log's secret is
+	// accessed. It is optional, and is not part of Sunlight's LogConfig. It
+	// overrides the role of the log's Env.
+	RoleARN string
+	// ExternalID is the external ID with which RoleARN is assumed. It is
+	// optional, and is not part of Sunlight's LogConfig.
+	ExternalID string
+	// AccountID is the ID of the AWS account to which the log's secret
+	// belongs. It is optional, and is not part of Sunlight's LogConfig. If it
+	// is set, a seed is only created for the log if GetCallerIdentity reports
+	// the same account.
+	AccountID string
+	// SecretID is the ID of the secret in which this log's seed is stored. It
+	// is optional, and is not part of Sunlight's LogConfig. If it is not set,
+	// loadConfig sets it from the SecretName template, or failing that, from
+	// the base name of the Secret path.
+	SecretID string
+}
+
+// publicKeyPath returns the path at which we write the PEM-encoded public key
+// derived from this log's seed. It is the PublicKey path if it is set, and
+// otherwise the Secret path with its extension (if any) replaced by ".pem", so
+// "/path/to/shard1.seed" becomes "/path/to/shard1.pem".
+func (lc logConfig) publicKeyPath() string {
+	if lc.PublicKey != "" {
+		return lc.PublicKey
+	}
+
+	return strings.TrimSuffix(lc.Secret, filepath.Ext(lc.Secret)) + ".pem"
+}
+
+// checkOutputPaths returns a problem for every file which more than one log,
+// or one log twice, would write to, such as a log whose Secret path already
+// ends in ".pem". Files are created exclusively, so the second write would fail
+// after the first had already been made, leaving a log half written.
+func checkOutputPaths(logs []logConfig) []error {
+	var problems []error
+
+	written := make(map[string]string)
+	for _, lc := range logs {
+		if lc.Secret == "" {
+			continue
+		}
+
+		for _, output := range []struct {
+			what string
+			path string
+		}{
+			{what: "seed", path: lc.Secret},
+			{what: "public key", path: lc.publicKeyPath()},
+		} {
+			path := filepath.Clean(output.path)
+			what := fmt.Sprintf("the %s of log %q", output.what, lc.Name)
+
+			if other, ok := written[path]; ok {
+				problems = append(problems, fmt.Errorf("%s and %s would both be written to %q", other, what, path))
+			} else {
+				written[path] = what
+			}
+		}
+	}
+
+	return problems
+}
+
+// validSecretID matches the characters which AWS allows in secret names.
+var validSecretID = regexp.MustCompile(`^[A-Za-z0-9/_+=.@-]+$`)
+
+// maxSecretIDLen is the longest secret name which AWS allows.
+const maxSecretIDLen = 512
+
+// resolveSecretID returns the ID of the secret in which this log's seed is
+// stored: SecretID if it is set, otherwise the result of executing the naming
+// template (if any) against the log's config, otherwise the base name of the
+// Secret path.
+func (lc logConfig) resolveSecretID(naming *template.Template) (string, error) {
+	if lc.SecretID != "" {
+		if !isValidSecretID(lc.SecretID) {
+			return "", fmt.Errorf("invalid secretid %q", lc.SecretID)
+		}
+
+		return lc.SecretID, nil
+	}
+
+	if naming == nil {
+		id := filepath.Base(lc.Secret)
+		if !isValidSecretID(id) {
+			return "", fmt.Errorf("secret %q has invalid base name %q to use as secret ID: set secretid or secretname", lc.Secret, id)
+		}
+
+		return id, nil
+	}
+
+	var id strings.Builder
+	err := naming.Execute(&id, lc)
+	if err != nil {
+		return "", fmt.Errorf("executing secret name template: %w", err)
+	}
+
+	if !isValidSecretID(id.String()) {
+		return "", fmt.Errorf("secret name template produced invalid secret ID %q", id.String())
+	}
+
+	return id.String(), nil
+}
+
+// isValidSecretID reports whether id is a secret ID we're willing to use. As
+// well as the characters and length which AWS allows, it rejects empty path
+// segments: an empty field in the template would produce IDs like
+// "sunlight//seed", which are valid but almost certainly not what was intended.
+func isValidSecretID(id string) bool {
+	return len(id) <= maxSecretIDLen && validSecretID.MatchString(id) && !strings.Contains(id, "//") &&
+		!strings.HasPrefix(id, "/") && !strings.HasSuffix(id, "/")
+}
+
+// parseSecretNameTemplate parses the SecretName template, if any.
+func (smc secretManagerConfig) parseSecretNameTemplate() (*template.Template, error) {
+	if smc.SecretName == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	naming, err := template.New("secretname").Option("missingkey=error").Parse(smc.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("parsing secret name template: %w", err)
+	}
+
+	return naming, nil
+}
+
+// check parses the SecretName template, if any, and returns it along with every
+// problem with the secretmanager section. Both loadConfig and validateConfig
+// use it, so that they always agree on which configs are valid.
+func (smc secretManagerConfig) check() (*template.Template, []error) {
+	var problems []error
+
+	naming, err := smc.parseSecretNameTemplate()
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	for _, check := range []func() error{
+		smc.checkSeedCreationPolicy,
+		smc.checkLaxPolicyMode,
+		smc.checkReplicaRegions,
+		smc.checkBackend,
+		smc.checkVault,
+		smc.checkRegions,
+		smc.checkEnvironments,
+		smc.checkEncoding,
+		smc.checkMinSeedLength,
+	} {
+		err = check()
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	return naming, problems
+}
+
+// checkLogIDFormat returns an error if the log's LogID is set but is not the
+// base64 encoding of a SHA-256 hash.
+func (lc logConfig) checkLogIDFormat() error {
+	if lc.LogID == "" {
+		return nil
+	}
+
+	logID, err := base64.StdEncoding.DecodeString(lc.LogID)
+	if err != nil || len(logID) != sha256.Size {
+		return fmt.Errorf("invalid log ID %q", lc.LogID)
+	}
+
+	return nil
+}
+
+// findLog returns the config of the log with the given name.
+func (c *config) findLog(name string) (logConfig, error) {
+	for _, lc := range c.Logs {
+		if lc.Name == name {
+			return lc, nil
+		}
+	}
+
+	return logConfig{}, fmt.Errorf("no log named %q in config", name) //nolint:exhaustruct
 }
 
 // loadConfig takes path to a yaml file and returns the seeds in that log file.
@@ -49,10 +348,51 @@ func loadConfig(configFile string) (*config, error) {
 		return nil, fmt.Errorf("no logs found in config file %q", configFile)
 	}
 
-	for _, log := range sunlightConfig.Logs {
+	naming, problems := sunlightConfig.SecretManager.check()
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config file %q: %w", configFile, problems[0])
+	}
+
+	// Two logs sharing a secret would share a key, which must never happen,
+	// so we make sure that every log resolves to a distinct secret ID.
+	secretIDs := make(map[string]string)
+
+	for i, log := range sunlightConfig.Logs {
 		if log.Name == "" || log.Inception == "" || log.Secret == "" {
 			return nil, fmt.Errorf("incomplete config for log %q in config file %q", log.Name, configFile)
 		}
+
+		_, err := parseInception(log.Inception)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for log %q in config file %q: %w", log.Name, configFile, err)
+		}
+
+		err = sunlightConfig.SecretManager.checkRole(log)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for log %q in config file %q: %w", log.Name, configFile, err)
+		}
+
+		err = log.checkLogIDFormat()
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for log %q in config file %q: %w", log.Name, configFile, err)
+		}
+
+		id, err := log.resolveSecretID(naming)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret ID for log %q in config file %q: %w", log.Name, configFile, err)
+		}
+
+		sunlightConfig.Logs[i].SecretID = id
+
+		if other, ok := secretIDs[id]; ok {
+			return nil, fmt.Errorf("logs %q and %q in config file %q both use secret ID %q", other, log.Name, configFile, id)
+		}
+		secretIDs[id] = log.Name
+	}
+
+	problems = checkOutputPaths(sunlightConfig.Logs)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config file %q: %w", configFile, problems[0])
 	}
 
 	return &sunlightConfig, nil
@@ -46,20 +46,117 @@ func TestLoadConfig(t *testing.T) {
 			want:    nil,
 			wantErr: "incomplete config for log",
 		},
+		{
+			name:    "invalid log ID",
+			input:   "badlogid.yaml",
+			want:    nil,
+			wantErr: "invalid log ID",
+		},
+		{
+			name:    "invalid inception",
+			input:   "badinception.yaml",
+			want:    nil,
+			wantErr: `inception "08/07/2024" is not a YYYY-MM-DD date`,
+		},
+		{
+			name:    "invalid seed creation policy",
+			input:   "badseedcreation.yaml",
+			want:    nil,
+			wantErr: `unknown seedcreation policy "always"`,
+		},
+		{
+			name:    "invalid role",
+			input:   "badrole.yaml",
+			want:    nil,
+			wantErr: `invalid config for log "test.tld/shard1" in config file "testdata/badrole.yaml": rolearn "arn:aws:iam::123456789012:role/sunlight" is in account 123456789012, but accountid is 210987654321`,
+		},
+		{
+			name:    "unknown environment",
+			input:   "badenv.yaml",
+			want:    nil,
+			wantErr: `invalid config for log "test.tld/shard1" in config file "testdata/badenv.yaml": env "prod" is not one of the configured environments ["staging"]`,
+		},
+		{
+			name:    "public key written over seed",
+			input:   "pemsecret.yaml",
+			want:    nil,
+			wantErr: `invalid config file "testdata/pemsecret.yaml": the seed of log "test.tld/shard1" and the public key of log "test.tld/shard1" would both be written to "/path/to/shard1.pem"`,
+		},
+		{
+			name:    "secret ID collision",
+			input:   "collision.yaml",
+			want:    nil,
+			wantErr: `logs "test.tld/shard1" and "other.tld/shard1" in config file "testdata/collision.yaml" both use secret ID "shard1.seed"`,
+		},
+		{
+			name:    "invalid secret name template",
+			input:   "badtemplate.yaml",
+			want:    nil,
+			wantErr: "parsing secret name template",
+		},
+		{
+			name:    "templated secret ID collision",
+			input:   "templatecollision.yaml",
+			want:    nil,
+			wantErr: `logs "test.tld/shard1" and "test.tld/shard2" in config file "testdata/templatecollision.yaml" both use secret ID "sunlight/staging/shard/seed"`,
+		},
 		{
 			name:  "happy path",
 			input: "happy.yaml",
 			want: &config{
+				Checkpoints: "/var/db/sunlight/checkpoints.db",
+				SecretManager: secretManagerConfig{
+					SecretName:          "",
+					SeedCreation:        "",
+					InceptionWindowDays: 0,
+					AllowedPrincipals:   nil,
+					LaxPolicy:           "",
+					KmsKeyID:            "alias/sunlight",
+					Description:         "Sunlight log seed",
+					Tags:                map[string]string{"team": "ct"},
+					ReplicaRegions:      []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: "alias/sunlight-replica"}},
+					Regions: []regionConfig{
+						{Region: "us-west-2", Endpoint: ""},
+						{Region: "us-east-2", Endpoint: "https://vpce-1234.secretsmanager.us-east-2.vpce.amazonaws.com"},
+					},
+					Environments: map[string]roleConfig{
+						"staging": {RoleARN: "arn:aws:iam::123456789012:role/sunlight", ExternalID: "sunlight-staging", AccountID: "123456789012"},
+					},
+				},
 				Logs: []logConfig{
 					{
-						Name:      "test.tld/shard1",
-						Inception: "2024-08-07",
-						Secret:    "/path/to/shard1.seed",
+						Name:             "test.tld/shard1",
+						ShortName:        "shard1",
+						Inception:        "2024-08-07",
+						Secret:           "/path/to/shard1.seed",
+						PublicKey:        "",
+						SubmissionPrefix: "https://test.tld/shard1/",
+						MonitoringPrefix: "https://mon.test.tld/shard1/",
+						NotAfterStart:    "2025-01-01T00:00:00Z",
+						NotAfterLimit:    "2025-07-01T00:00:00Z",
+						LocalDirectory:   "/var/db/sunlight/shard1",
+						LogID:            "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0=",
+						Description:      "Test 'Shard1'",
+						Checkpoint:       "",
+						Env:              "",
+						SecretID:         "shard1.seed",
 					},
 					{
-						Name:      "test.tld/shard2",
-						Inception: "2024-08-07",
-						Secret:    "/path/to/shard2.seed",
+						Name:             "test.tld/shard2",
+						ShortName:        "shard2",
+						Inception:        "2024-08-07",
+						Secret:           "/path/to/shard2.seed",
+						PublicKey:        "",
+						SubmissionPrefix: "https://test.tld/shard2/",
+						MonitoringPrefix: "https://mon.test.tld/shard2/",
+						NotAfterStart:    "2025-07-01T00:00:00Z",
+						NotAfterLimit:    "2026-01-01T00:00:00Z",
+						LocalDirectory:   "",
+						LogID:            "",
+						Description:      "",
+						Checkpoint:       "/var/db/sunlight/shard2/checkpoint.bak",
+						Env:              "staging",
+						SecretID:         "shard2.seed",
 					},
 				},
 			},
@@ -87,3 +184,197 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestPublicKeyPath(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		secret    string
+		publicKey string
+		want      string
+	}{
+		{secret: "/path/to/shard1.seed", publicKey: "", want: "/path/to/shard1.pem"},
+		{secret: "/path/to/shard1", publicKey: "", want: "/path/to/shard1.pem"},
+		{secret: "/path.d/to/shard1", publicKey: "", want: "/path.d/to/shard1.pem"},
+		{secret: "/path/to/test.tld-shard1.key", publicKey: "", want: "/path/to/test.tld-shard1.pem"},
+		{secret: "/path/to/shard1.seed", publicKey: "/etc/sunlight/shard1.pem", want: "/etc/sunlight/shard1.pem"},
+	} {
+		t.Run(tc.secret+" "+tc.publicKey, func(t *testing.T) {
+			t.Parallel()
+
+			got := logConfig{Secret: tc.secret, PublicKey: tc.publicKey}.publicKeyPath() //nolint:exhaustruct
+			if got != tc.want {
+				t.Errorf("publicKeyPath() = %q, but want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindLog(t *testing.T) {
+	t.Parallel()
+
+	conf, err := loadConfig(filepath.Join("testdata", "happy.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() = %q, but want success", err)
+	}
+
+	got, err := conf.findLog("test.tld/shard2")
+	if err != nil {
+		t.Errorf("findLog() = %q, but want success", err)
+	} else if got.Secret != "/path/to/shard2.seed" {
+		t.Errorf("findLog() = %#v, but want log with secret %q", got, "/path/to/shard2.seed")
+	}
+
+	_, err = conf.findLog("test.tld/shard3")
+	if err == nil || !strings.Contains(err.Error(), "no log named") {
+		t.Errorf("findLog() = %v, but want error %q", err, "no log named")
+	}
+}
+
+func TestResolveSecretID(t *testing.T) {
+	t.Parallel()
+
+	naming, err := secretManagerConfig{SecretName: "sunlight/{{.Env}}/{{.ShortName}}/seed"}.parseSecretNameTemplate()
+	if err != nil {
+		t.Fatalf("parseSecretNameTemplate() = %q, but want success", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		conf    logConfig
+		naming  bool
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "no template",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", ShortName: "shard1"}, //nolint:exhaustruct
+			naming:  false,
+			want:    "shard1.seed",
+			wantErr: "",
+		},
+		{
+			name:    "template",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", ShortName: "shard1"}, //nolint:exhaustruct
+			naming:  true,
+			want:    "sunlight/staging/shard1/seed",
+			wantErr: "",
+		},
+		{
+			name:    "override",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", SecretID: "legacy-shard1"}, //nolint:exhaustruct
+			naming:  true,
+			want:    "legacy-shard1",
+			wantErr: "",
+		},
+		{
+			name:    "invalid override",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", SecretID: "legacy shard1"}, //nolint:exhaustruct
+			naming:  true,
+			want:    "",
+			wantErr: `invalid secretid "legacy shard1"`,
+		},
+		{
+			name:    "override with empty segment",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", SecretID: "sunlight//shard1"}, //nolint:exhaustruct
+			naming:  false,
+			want:    "",
+			wantErr: `invalid secretid "sunlight//shard1"`,
+		},
+		{
+			name:    "override with leading slash",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", SecretID: "/sunlight/shard1"}, //nolint:exhaustruct
+			naming:  false,
+			want:    "",
+			wantErr: `invalid secretid "/sunlight/shard1"`,
+		},
+		{
+			name:    "invalid base name",
+			conf:    logConfig{Secret: "/path/to/shard 1.seed", Env: "staging", ShortName: "shard1"}, //nolint:exhaustruct
+			naming:  false,
+			want:    "",
+			wantErr: `invalid base name "shard 1.seed"`,
+		},
+		{
+			name:    "longest override",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", SecretID: strings.Repeat("a", 512)}, //nolint:exhaustruct
+			naming:  false,
+			want:    strings.Repeat("a", 512),
+			wantErr: "",
+		},
+		{
+			name:    "override too long",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", SecretID: strings.Repeat("a", 513)}, //nolint:exhaustruct
+			naming:  false,
+			want:    "",
+			wantErr: "invalid secretid",
+		},
+		{
+			name:    "empty field",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", ShortName: "shard1"}, //nolint:exhaustruct
+			naming:  true,
+			want:    "",
+			wantErr: `produced invalid secret ID "sunlight//shard1/seed"`,
+		},
+		{
+			name:    "invalid characters",
+			conf:    logConfig{Secret: "/path/to/shard1.seed", Env: "staging", ShortName: "shard 1"}, //nolint:exhaustruct
+			naming:  true,
+			want:    "",
+			wantErr: `produced invalid secret ID "sunlight/staging/shard 1/seed"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got string
+			var err error
+			if tc.naming {
+				got, err = tc.conf.resolveSecretID(naming)
+			} else {
+				got, err = tc.conf.resolveSecretID(nil)
+			}
+
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("resolveSecretID() = %q, but want error %q", got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("resolveSecretID() = %q, but want error %q", err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("resolveSecretID() = %q, but want %q", err, tc.want)
+				} else if got != tc.want {
+					t.Errorf("resolveSecretID() = %q, but want %q", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretManagerConfigCheck(t *testing.T) {
+	t.Parallel()
+
+	smc := secretManagerConfig{SecretName: "{{.ShortName", Backend: "gcp"} //nolint:exhaustruct
+
+	naming, problems := smc.check()
+	if naming != nil {
+		t.Errorf("check() returned template %q, but want none", naming.Name())
+	}
+
+	want := []string{"parsing secret name template", `unknown backend "gcp"`}
+	if len(problems) != len(want) {
+		t.Fatalf("check() = %q, but want %d problems", problems, len(want))
+	}
+
+	for i := range problems {
+		if !strings.Contains(problems[i].Error(), want[i]) {
+			t.Errorf("check() problem %d = %q, but want %q", i, problems[i], want[i])
+		}
+	}
+
+	naming, problems = secretManagerConfig{SecretName: "{{.ShortName}}"}.check() //nolint:exhaustruct
+	if naming == nil || len(problems) != 0 {
+		t.Errorf("check() = %v, %q, but want a template and no problems", naming, problems)
+	}
+}
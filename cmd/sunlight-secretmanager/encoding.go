@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// The encodings in which seeds can be stored in secrets.
+const (
+	// encodingBinary stores the seed as it is in SecretBinary. It is the
+	// default.
+	encodingBinary = "binary"
+	// encodingBase64 stores the seed in SecretString, encoded with standard
+	// base64.
+	encodingBase64 = "base64"
+	// encodingHex stores the seed in SecretString, encoded as hexadecimal.
+	encodingHex = "hex"
+)
+
+// checkEncoding returns an error if the Encoding setting is not one we know.
+func (smc secretManagerConfig) checkEncoding() error {
+	switch smc.Encoding {
+	case "", encodingBinary, encodingBase64, encodingHex:
+		return nil
+	default:
+		return fmt.Errorf("unknown encoding %q: must be %s, %s or %s", smc.Encoding, encodingBinary, encodingBase64, encodingHex)
+	}
+}
+
+// decodeSeed returns the seed held by the version of the secret with the given
+// ID whose SecretBinary and SecretString are binary and str. Exactly one of
+// them must be set, and it must be the one which encoding uses. Since
+// Terraform and the AWS console set secrets as strings, a SecretString seed is
+// never mistaken for an empty one.
+func decodeSeed(id string, encoding string, binary []byte, str *string) ([]byte, error) {
+	switch {
+	case len(binary) != 0 && str != nil:
+		return nil, fmt.Errorf("secret %q has both SecretBinary and SecretString", id)
+	case len(binary) == 0 && str == nil:
+		return nil, fmt.Errorf("secret %q has neither SecretBinary nor SecretString", id)
+	}
+
+	if encoding == "" || encoding == encodingBinary {
+		if str != nil {
+			return nil, fmt.Errorf("secret %q has a SecretString, but encoding is %s", id, encodingBinary)
+		}
+
+		return binary, nil
+	}
+
+	if str == nil {
+		return nil, fmt.Errorf("secret %q has a SecretBinary, but encoding is %s", id, encoding)
+	}
+
+	var seed []byte
+	var err error
+
+	switch encoding {
+	case encodingBase64:
+		seed, err = base64.StdEncoding.DecodeString(*str)
+	case encodingHex:
+		seed, err = hex.DecodeString(*str)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decoding SecretString of secret %q as %s: %w", id, encoding, err)
+	}
+
+	return seed, nil
+}
+
+// encodeSeed returns the SecretBinary and SecretString with which to store
+// seed with the given encoding.
+func encodeSeed(encoding string, seed []byte) ([]byte, *string) {
+	switch encoding {
+	case encodingBase64:
+		str := base64.StdEncoding.EncodeToString(seed)
+
+		return nil, &str
+	case encodingHex:
+		str := hex.EncodeToString(seed)
+
+		return nil, &str
+	default:
+		return seed, nil
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckEncoding(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		encoding string
+		wantErr  bool
+	}{
+		{encoding: "", wantErr: false},
+		{encoding: "binary", wantErr: false},
+		{encoding: "base64", wantErr: false},
+		{encoding: "hex", wantErr: false},
+		{encoding: "base32", wantErr: true},
+	} {
+		t.Run(tc.encoding, func(t *testing.T) {
+			t.Parallel()
+
+			err := secretManagerConfig{Encoding: tc.encoding}.checkEncoding() //nolint:exhaustruct
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkEncoding() = %v, but want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitSeedEncoding(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		id       string
+		exists   bool
+		encoding string
+	}{
+		{name: "put binary", id: "terraform", exists: true, encoding: "binary"},
+		{name: "put base64", id: "terraform", exists: true, encoding: "base64"},
+		{name: "put hex", id: "terraform", exists: true, encoding: "hex"},
+		{name: "create base64", id: "absent", exists: false, encoding: "base64"},
+		{name: "create hex", id: "absent", exists: false, encoding: "hex"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSM := &fakeSecretsManager{}               //nolint:exhaustruct
+			meta := secretMetadata{Encoding: tc.encoding} //nolint:exhaustruct
+
+			seed, versionID, err := initSeed(t.Context(), testSM, tc.id, tc.exists, meta, newSeed())
+			if err != nil {
+				t.Fatalf("initSeed(%q) = %q, but want success", tc.id, err)
+			}
+
+			if len(seed) != seedLen {
+				t.Fatalf("initSeed(%q) = seed of length %d, but want %d", tc.id, len(seed), seedLen)
+			}
+
+			_, isBinary := testSM.lookup(tc.id)
+			_, isString := testSM.lookupString(tc.id)
+			if isBinary != (tc.encoding == encodingBinary) || isString == (tc.encoding == encodingBinary) {
+				t.Errorf("initSeed(%q) stored SecretBinary: %t, SecretString: %t, but want the one encoding %s uses", tc.id, isBinary, isString, tc.encoding)
+			}
+
+			// The seed must read back the same, and not as an empty secret.
+			got, gotID, err := fetchSeed(t.Context(), testSM, tc.id, tc.encoding)
+			if err != nil || !bytes.Equal(got, seed) || gotID != versionID {
+				t.Errorf("fetchSeed(%q) = %x from version %q, %v, but want %x from version %q", tc.id, got, gotID, err, seed, versionID)
+			}
+		})
+	}
+}
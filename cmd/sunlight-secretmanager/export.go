@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultMMD is the Maximum Merge Delay, in seconds, which we declare for logs
+// in generated log list entries unless told otherwise. It is the 24 hours which
+// CT log policies expect; Sunlight sequences submissions within a few seconds,
+// but a log which misses its declared MMD is disqualified, so we don't promise
+// more than we must.
+const defaultMMD = 86400
+
+// exportLogListCommand prints a v3 log list fragment describing every log in
+// the config, with keys derived from the seeds stored in Secrets Manager. The
+// logs are listed under operators[].logs[], or under operators[].tiled_logs[]
+// with -tiled. It checks the seeds as writeCommand does, but never creates or
+// pins a seed and never writes anything to disk.
+func exportLogListCommand(args []string) {
+	flagset := flag.NewFlagSet("sunlight-secretmanager export-loglist", flag.ContinueOnError)
+	configFlag := flagset.String("config", "", "Path to YAML config file")
+	operatorFlag := flagset.String("operator", "", "Name of the log operator")
+	emailFlag := flagset.String("email", "", "Comma-separated contact email addresses of the log operator")
+	mmdFlag := flagset.Int("mmd", defaultMMD, "Maximum Merge Delay of the logs, in seconds")
+	tiledFlag := flagset.Bool("tiled", false, "List the logs under tiled_logs rather than logs")
+
+	err := flagset.Parse(args)
+	if err != nil {
+		log.Fatalf("Error parsing flags: %s", err)
+	}
+
+	config, err := loadConfig(*configFlag)
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+
+	ctx := context.Background()
+
+	backend, err := newBackend(ctx, config.SecretManager)
+	if err != nil {
+		log.Fatalf("Error creating seed backend: %s", err)
+	}
+
+	operator := logListOperator{
+		Name:      *operatorFlag,
+		Email:     []string{},
+		Logs:      []logListEntry{},
+		TiledLogs: []logListEntry{},
+	}
+	if *emailFlag != "" {
+		operator.Email = strings.Split(*emailFlag, ",")
+	}
+
+	seeds := make(seedSet)
+
+	for _, logConf := range config.Logs {
+		store, err := backend.store(logConf)
+		if err != nil {
+			log.Fatalf("Error fetching seed for log %q: %v", logConf.Name, err)
+		}
+
+		seed, keys, err := fetchCheckedSeed(ctx, config, logConf, store)
+		if err != nil {
+			log.Fatalf("Error checking seed for log %q: %v", logConf.Name, err)
+		}
+
+		err = seeds.add(logConf.Name, seed)
+		if err != nil {
+			log.Fatalf("Invalid seed for log %q: %v", logConf.Name, err)
+		}
+
+		entry, err := newLogListEntry(logConf, keys, *mmdFlag)
+		if err != nil {
+			log.Fatalf("Error describing log %q: %v", logConf.Name, err)
+		}
+
+		if *tiledFlag {
+			operator.TiledLogs = append(operator.TiledLogs, entry)
+		} else {
+			operator.Logs = append(operator.Logs, entry)
+		}
+	}
+
+	out, err := json.MarshalIndent(logList{Operators: []logListOperator{operator}}, "", "  ")
+	if err != nil {
+		log.Fatalf("Error encoding log list: %s", err)
+	}
+
+	_, _ = os.Stdout.Write(append(out, '\n'))
+}
+
+// newLogListEntry describes a configured log in a v3 log list.
+// Sunlight logs implement c2sp.org/static-ct-api, so they are listed with a
+// submission URL and a monitoring URL rather than a single RFC 6962 URL.
+func newLogListEntry(lc logConfig, keys *logKeys, mmd int) (logListEntry, error) {
+	if lc.SubmissionPrefix == "" || lc.MonitoringPrefix == "" {
+		return logListEntry{}, errors.New("log list entries require both submissionprefix and monitoringprefix") //nolint:exhaustruct
+	}
+
+	description := lc.Description
+	if description == "" {
+		description = lc.Name
+	}
+
+	var interval *temporalInterval
+	if lc.NotAfterStart != "" || lc.NotAfterLimit != "" {
+		start, err := time.Parse(time.RFC3339, lc.NotAfterStart)
+		if err != nil {
+			return logListEntry{}, fmt.Errorf("parsing notafterstart: %w", err) //nolint:exhaustruct
+		}
+
+		limit, err := time.Parse(time.RFC3339, lc.NotAfterLimit)
+		if err != nil {
+			return logListEntry{}, fmt.Errorf("parsing notafterlimit: %w", err) //nolint:exhaustruct
+		}
+
+		interval = &temporalInterval{
+			StartInclusive: start.UTC().Format(time.RFC3339),
+			EndExclusive:   limit.UTC().Format(time.RFC3339),
+		}
+	}
+
+	return logListEntry{
+		Description:      description,
+		LogID:            keys.logIDBase64(),
+		Key:              keys.spkiBase64(),
+		URL:              "",
+		SubmissionURL:    strings.TrimSuffix(lc.SubmissionPrefix, "/") + "/",
+		MonitoringURL:    strings.TrimSuffix(lc.MonitoringPrefix, "/") + "/",
+		MMD:              mmd,
+		TemporalInterval: interval,
+	}, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewLogListEntry(t *testing.T) {
+	t.Parallel()
+
+	keys, err := deriveKeys(make([]byte, seedLen))
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		conf    logConfig
+		want    logListEntry
+		wantErr string
+	}{
+		{
+			name: "missing prefixes",
+			conf: logConfig{ //nolint:exhaustruct
+				Name:             "test.tld/shard1",
+				SubmissionPrefix: "https://test.tld/shard1/",
+			},
+			want:    logListEntry{}, //nolint:exhaustruct
+			wantErr: "require both submissionprefix and monitoringprefix",
+		},
+		{
+			name: "bad notafterlimit",
+			conf: logConfig{ //nolint:exhaustruct
+				Name:             "test.tld/shard1",
+				SubmissionPrefix: "https://test.tld/shard1/",
+				MonitoringPrefix: "https://mon.test.tld/shard1/",
+				NotAfterStart:    "2025-01-01T00:00:00Z",
+				NotAfterLimit:    "2025-07-01",
+			},
+			want:    logListEntry{}, //nolint:exhaustruct
+			wantErr: "parsing notafterlimit",
+		},
+		{
+			name: "not sharded",
+			conf: logConfig{ //nolint:exhaustruct
+				Name:             "test.tld/shard1",
+				SubmissionPrefix: "https://test.tld/shard1",
+				MonitoringPrefix: "https://mon.test.tld/shard1",
+			},
+			want: logListEntry{
+				Description:      "test.tld/shard1",
+				LogID:            "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0=",
+				Key:              "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEHm9VJxsjVA4jUWTJirhJFLJnpHLl24Mib2kqf38/A76cCUdoYipPZlhEl7WKde+4w9vG13ecEcRN3dCaiyfR0w==",
+				URL:              "",
+				SubmissionURL:    "https://test.tld/shard1/",
+				MonitoringURL:    "https://mon.test.tld/shard1/",
+				MMD:              defaultMMD,
+				TemporalInterval: nil,
+			},
+			wantErr: "",
+		},
+		{
+			name: "happy path",
+			conf: logConfig{ //nolint:exhaustruct
+				Name:             "test.tld/shard1",
+				Description:      "Test 'Shard1'",
+				SubmissionPrefix: "https://test.tld/shard1/",
+				MonitoringPrefix: "https://mon.test.tld/shard1/",
+				NotAfterStart:    "2025-01-01T00:00:00Z",
+				NotAfterLimit:    "2025-07-01T02:00:00+02:00",
+			},
+			want: logListEntry{
+				Description:   "Test 'Shard1'",
+				LogID:         "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0=",
+				Key:           "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEHm9VJxsjVA4jUWTJirhJFLJnpHLl24Mib2kqf38/A76cCUdoYipPZlhEl7WKde+4w9vG13ecEcRN3dCaiyfR0w==",
+				URL:           "",
+				SubmissionURL: "https://test.tld/shard1/",
+				MonitoringURL: "https://mon.test.tld/shard1/",
+				MMD:           defaultMMD,
+				TemporalInterval: &temporalInterval{
+					StartInclusive: "2025-01-01T00:00:00Z",
+					EndExclusive:   "2025-07-01T00:00:00Z",
+				},
+			},
+			wantErr: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := newLogListEntry(tc.conf, keys, defaultMMD)
+			if tc.wantErr != "" { //nolint:nestif
+				if err == nil {
+					t.Errorf("newLogListEntry() = %#v, but want error %q", got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("newLogListEntry() = %q, but want error %q", err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("newLogListEntry() = %q, but want %#v", err, tc.want)
+				} else if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("newLogListEntry() = %#v, but want %#v", got, tc.want)
+				}
+			}
+		})
+	}
+}
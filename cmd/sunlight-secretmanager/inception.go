@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// The seed creation policies, which decide when sunlight-secretmanager may
+// create a new seed for a log whose secret is empty. Seeds are never created
+// after a log's Inception date, whatever the policy.
+const (
+	// seedCreationBeforeInception allows seeds to be created at any time up to
+	// and including the log's Inception date.
+	seedCreationBeforeInception = "before-inception"
+	// seedCreationInceptionWindow allows seeds to be created on the log's
+	// Inception date, or up to InceptionWindowDays days before it. It is the
+	// default, and with the default window of zero days it only allows seeds to
+	// be created on the Inception date itself.
+	seedCreationInceptionWindow = "inception-window"
+	// seedCreationNever never allows seeds to be created. Empty secrets are
+	// always treated as errors.
+	seedCreationNever = "never"
+)
+
+// parseInception parses a log's Inception date, which is a YYYY-MM-DD date in
+// UTC.
+func parseInception(inception string) (time.Time, error) {
+	date, err := time.ParseInLocation(time.DateOnly, inception, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("inception %q is not a YYYY-MM-DD date", inception)
+	}
+
+	return date, nil
+}
+
+// checkSeedCreationPolicy returns an error if the seed creation policy is not
+// one we know, or if its window is negative.
+func (smc secretManagerConfig) checkSeedCreationPolicy() error {
+	switch smc.SeedCreation {
+	case "", seedCreationInceptionWindow, seedCreationBeforeInception, seedCreationNever:
+	default:
+		return fmt.Errorf("unknown seedcreation policy %q: must be one of %s, %s, %s",
+			smc.SeedCreation, seedCreationInceptionWindow, seedCreationBeforeInception, seedCreationNever)
+	}
+
+	if smc.InceptionWindowDays < 0 {
+		return fmt.Errorf("inceptionwindowdays %d is negative", smc.InceptionWindowDays)
+	}
+
+	if smc.InceptionWindowDays != 0 && smc.SeedCreation != "" && smc.SeedCreation != seedCreationInceptionWindow {
+		return fmt.Errorf("inceptionwindowdays is only used by the %s seedcreation policy", seedCreationInceptionWindow)
+	}
+
+	return nil
+}
+
+// checkSeedCreation returns an error explaining which rule forbids creating a
+// seed at time now for a log with the given Inception date, or nil if the seed
+// creation policy allows it. Dates are compared in UTC.
+func (smc secretManagerConfig) checkSeedCreation(inception string, now time.Time) error {
+	err := smc.checkSeedCreationPolicy()
+	if err != nil {
+		return err
+	}
+
+	if smc.SeedCreation == seedCreationNever {
+		return fmt.Errorf("the %s seedcreation policy forbids creating seeds", seedCreationNever)
+	}
+
+	date, err := parseInception(inception)
+	if err != nil {
+		return err
+	}
+
+	y, m, d := now.UTC().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	if today.After(date) {
+		return fmt.Errorf("inception date %s has passed, and seeds are never created after inception", inception)
+	}
+
+	if smc.SeedCreation == seedCreationBeforeInception {
+		return nil
+	}
+
+	earliest := date.AddDate(0, 0, -smc.InceptionWindowDays)
+	if today.Before(earliest) {
+		if smc.InceptionWindowDays == 0 {
+			return fmt.Errorf("the %s seedcreation policy only allows creating seeds on the inception date %s",
+				seedCreationInceptionWindow, inception)
+		}
+
+		return fmt.Errorf("the %s seedcreation policy only allows creating seeds from %s, %d days before the inception date %s",
+			seedCreationInceptionWindow, earliest.Format(time.DateOnly), smc.InceptionWindowDays, inception)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSeedCreation(t *testing.T) {
+	t.Parallel()
+
+	// Just before midnight in New York is already the next day in UTC.
+	newYork := time.FixedZone("EDT", -4*60*60)
+	now := time.Date(2024, time.August, 6, 22, 0, 0, 0, newYork)
+
+	for _, tc := range []struct {
+		name      string
+		policy    secretManagerConfig
+		inception string
+		wantErr   string
+	}{
+		{
+			name:      "inception date in UTC",
+			policy:    secretManagerConfig{SeedCreation: "", InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "2024-08-07",
+			wantErr:   "",
+		},
+		{
+			name:      "before inception date",
+			policy:    secretManagerConfig{SeedCreation: "", InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "2024-08-08",
+			wantErr:   "only allows creating seeds on the inception date 2024-08-08",
+		},
+		{
+			name:      "within window",
+			policy:    secretManagerConfig{SeedCreation: seedCreationInceptionWindow, InceptionWindowDays: 14}, //nolint:exhaustruct
+			inception: "2024-08-21",
+			wantErr:   "",
+		},
+		{
+			name:      "outside window",
+			policy:    secretManagerConfig{SeedCreation: seedCreationInceptionWindow, InceptionWindowDays: 14}, //nolint:exhaustruct
+			inception: "2024-08-22",
+			wantErr:   "only allows creating seeds from 2024-08-08, 14 days before the inception date 2024-08-22",
+		},
+		{
+			name:      "any time before inception",
+			policy:    secretManagerConfig{SeedCreation: seedCreationBeforeInception, InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "2025-08-07",
+			wantErr:   "",
+		},
+		{
+			name:      "after inception",
+			policy:    secretManagerConfig{SeedCreation: seedCreationBeforeInception, InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "2024-08-06",
+			wantErr:   "inception date 2024-08-06 has passed",
+		},
+		{
+			name:      "never",
+			policy:    secretManagerConfig{SeedCreation: seedCreationNever, InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "2024-08-07",
+			wantErr:   "the never seedcreation policy forbids creating seeds",
+		},
+		{
+			name:      "unknown policy",
+			policy:    secretManagerConfig{SeedCreation: "always", InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "2024-08-07",
+			wantErr:   `unknown seedcreation policy "always"`,
+		},
+		{
+			name:      "negative window",
+			policy:    secretManagerConfig{SeedCreation: "", InceptionWindowDays: -1}, //nolint:exhaustruct
+			inception: "2024-08-07",
+			wantErr:   "inceptionwindowdays -1 is negative",
+		},
+		{
+			name:      "window without window policy",
+			policy:    secretManagerConfig{SeedCreation: seedCreationBeforeInception, InceptionWindowDays: 7}, //nolint:exhaustruct
+			inception: "2024-08-07",
+			wantErr:   "inceptionwindowdays is only used by the inception-window seedcreation policy",
+		},
+		{
+			name:      "invalid inception",
+			policy:    secretManagerConfig{SeedCreation: "", InceptionWindowDays: 0}, //nolint:exhaustruct
+			inception: "08/07/2024",
+			wantErr:   `inception "08/07/2024" is not a YYYY-MM-DD date`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.policy.checkSeedCreation(tc.inception, now)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("checkSeedCreation(%q) = nil, but want error %q", tc.inception, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("checkSeedCreation(%q) = %q, but want error %q", tc.inception, err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("checkSeedCreation(%q) = %q, but want success", tc.inception, err)
+			}
+		})
+	}
+}
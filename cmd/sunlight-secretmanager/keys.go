@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// hkdfSalt is the HKDF salt Sunlight uses when deriving each of a log's keys
+// from its seed.
+const hkdfSalt = "sunlight"
+
+// ecdsaKeyInfo is the HKDF info string Sunlight uses to derive the secret from
+// which the log's ECDSA P-256 key is generated.
+const ecdsaKeyInfo = "ECDSA P-256 log key"
+
+// ecdsaKeygenPersonalization is the HMAC_DRBG personalization string used by
+// filippo.io/keygen, which Sunlight uses to turn the derived secret into a key.
+const ecdsaKeygenPersonalization = "det ECDSA key gen P-256"
+
+// ed25519KeyInfo is the HKDF info string Sunlight uses to derive the seed of
+// the log's Ed25519 key, with which it signs checkpoints as a witness would.
+const ed25519KeyInfo = "Ed25519 log key"
+
+// maxKeygenAttempts bounds the rejection sampling loop in deriveECDSAKey. The
+// chance of a single candidate being rejected for P-256 is about 2^-32, so this
+// limit is never expected to be hit.
+const maxKeygenAttempts = 100
+
+// logKeys holds the public key material which Sunlight derives from a log's
+// seed. We never keep the private keys around: we only need them to compute
+// the public keys which we write to disk or check against other sources.
+type logKeys struct {
+	// PublicKey is the log's ECDSA P-256 public key, which is used to verify
+	// its SCTs and checkpoints.
+	PublicKey *ecdsa.PublicKey
+	// SPKI is the DER-encoded SubjectPublicKeyInfo of PublicKey.
+	SPKI []byte
+	// WitnessKey is the log's Ed25519 public key, which is used to verify the
+	// signed-note signatures on its checkpoints.
+	WitnessKey ed25519.PublicKey
+}
+
+// deriveKeys derives a log's keys from its seed in exactly the same way as
+// Sunlight does, so that the results can be checked against (and used in
+// place of) the keys of a running Sunlight instance.
+func deriveKeys(seed []byte) (*logKeys, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("cannot derive keys from empty seed")
+	}
+
+	ecdsaSecret, err := hkdf.Key(sha256.New, seed, []byte(hkdfSalt), ecdsaKeyInfo, seedLen)
+	if err != nil {
+		return nil, fmt.Errorf("deriving ECDSA secret: %w", err)
+	}
+
+	priv, err := deriveECDSAKey(ecdsaSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(priv.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling ECDSA public key: %w", err)
+	}
+
+	// Round-trip through the SPKI to get an *ecdsa.PublicKey, which is what
+	// the rest of the crypto ecosystem expects for verifying signatures.
+	parsed, err := x509.ParsePKIXPublicKey(spki)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ECDSA public key: %w", err)
+	}
+
+	pub, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("parsed ECDSA public key has unexpected type %T", parsed)
+	}
+
+	ed25519Seed, err := hkdf.Key(sha256.New, seed, []byte(hkdfSalt), ed25519KeyInfo, ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("deriving Ed25519 seed: %w", err)
+	}
+
+	witnessKey, ok := ed25519.NewKeyFromSeed(ed25519Seed).Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("derived Ed25519 public key has unexpected type")
+	}
+
+	return &logKeys{
+		PublicKey:  pub,
+		SPKI:       spki,
+		WitnessKey: witnessKey,
+	}, nil
+}
+
+// publicKeyPEM returns the log's ECDSA public key as a PEM-encoded
+// SubjectPublicKeyInfo, the format Sunlight expects in its public key file.
+func (k *logKeys) publicKeyPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: nil,
+		Bytes:   k.SPKI,
+	})
+}
+
+// spkiBase64 returns the log's SPKI in base64, the encoding used by CT log
+// lists.
+func (k *logKeys) spkiBase64() string {
+	return base64.StdEncoding.EncodeToString(k.SPKI)
+}
+
+// logID returns the log's RFC 6962 log ID, the SHA-256 hash of its SPKI.
+func (k *logKeys) logID() [sha256.Size]byte {
+	return sha256.Sum256(k.SPKI)
+}
+
+// logIDBase64 returns the log's RFC 6962 log ID in base64, the encoding used by
+// CT log lists.
+func (k *logKeys) logIDBase64() string {
+	id := k.logID()
+
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+// checkLogID returns an error if want is not empty and is not the base64
+// encoding of the log's ID. This protects against materializing the wrong
+// seed for a log whose ID is already known to the world.
+func (k *logKeys) checkLogID(want string) error {
+	if want == "" {
+		return nil
+	}
+
+	got := k.logIDBase64()
+	if got != want {
+		return fmt.Errorf("derived log ID %s does not match expected log ID %s", got, want)
+	}
+
+	return nil
+}
+
+// verifierKey returns the signed-note verifier key (of the form
+// "name+keyhash+base64") for the log's Ed25519 key, which witnesses and
+// monitors use to verify the log's checkpoints. The name must be the log's
+// Name, which Sunlight uses as the checkpoint origin.
+func (k *logKeys) verifierKey(name string) (string, error) {
+	vkey, err := note.NewEd25519VerifierKey(name, k.WitnessKey)
+	if err != nil {
+		return "", fmt.Errorf("encoding verifier key for %q: %w", name, err)
+	}
+
+	// NewEd25519VerifierKey doesn't check that the name is valid, but parsing
+	// the key back with NewVerifier does.
+	_, err = note.NewVerifier(vkey)
+	if err != nil {
+		return "", fmt.Errorf("encoding verifier key for %q: %w", name, err)
+	}
+
+	return vkey, nil
+}
+
+// deriveECDSAKey deterministically generates a P-256 key from secret, following
+// the procedure of filippo.io/keygen.ECDSA: candidate scalars are drawn from an
+// HMAC_DRBG seeded with the secret, and rejected until one is in [1, N-1].
+func deriveECDSAKey(secret []byte) (*ecdh.PrivateKey, error) {
+	drbg := newHMACDRBG(secret, []byte(ecdsaKeygenPersonalization))
+
+	candidate := make([]byte, seedLen)
+	for range maxKeygenAttempts {
+		drbg.generate(candidate)
+
+		// crypto/ecdh rejects scalars which are zero or not less than the
+		// order of the curve, which is exactly the check we need.
+		priv, err := ecdh.P256().NewPrivateKey(candidate)
+		if err == nil {
+			return priv, nil
+		}
+	}
+
+	return nil, errors.New("failed to generate ECDSA key: too many rejected candidates")
+}
+
+// hmacDRBG is a minimal HMAC_DRBG with SHA-256, as specified in NIST SP 800-90A
+// Rev. 1, Section 10.1.2. It supports neither reseeding nor additional input,
+// because deterministic key generation never needs either.
+type hmacDRBG struct {
+	k []byte
+	v []byte
+}
+
+// newHMACDRBG instantiates an HMAC_DRBG with the given entropy input and
+// personalization string, and an empty nonce.
+func newHMACDRBG(entropy []byte, personalization []byte) *hmacDRBG {
+	drbg := &hmacDRBG{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range drbg.v {
+		drbg.v[i] = 0x01
+	}
+
+	seedMaterial := make([]byte, 0, len(entropy)+len(personalization))
+	seedMaterial = append(seedMaterial, entropy...)
+	seedMaterial = append(seedMaterial, personalization...)
+	drbg.update(seedMaterial)
+
+	return drbg
+}
+
+// update is the HMAC_DRBG_Update function.
+func (d *hmacDRBG) update(data []byte) {
+	d.k = hmacSHA256(d.k, d.v, []byte{0x00}, data)
+	d.v = hmacSHA256(d.k, d.v)
+
+	if len(data) == 0 {
+		return
+	}
+
+	d.k = hmacSHA256(d.k, d.v, []byte{0x01}, data)
+	d.v = hmacSHA256(d.k, d.v)
+}
+
+// generate fills out with pseudorandom bytes. It is the HMAC_DRBG_Generate
+// function without additional input.
+func (d *hmacDRBG) generate(out []byte) {
+	for n := 0; n < len(out); n += len(d.v) {
+		d.v = hmacSHA256(d.k, d.v)
+		copy(out[n:], d.v)
+	}
+
+	d.update(nil)
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the concatenation of data.
+func hmacSHA256(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, b := range data {
+		mac.Write(b)
+	}
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// TestHMACDRBG checks our HMAC_DRBG against the first SHA-256 no-reseed,
+// no-personalization test vector from NIST's CAVP HMAC_DRBG test suite. The
+// nonce is appended to the entropy input, which produces the same seed material.
+func TestHMACDRBG(t *testing.T) {
+	t.Parallel()
+
+	entropy, _ := hex.DecodeString("ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488" +
+		"659ba96c601dc69fc902940805ec0ca8")
+	want, _ := hex.DecodeString("e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89" +
+		"d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc1" +
+		"07694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668" +
+		"961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8")
+
+	drbg := newHMACDRBG(entropy, nil)
+	got := make([]byte, len(want))
+	// The test vector discards the output of the first call to Generate.
+	drbg.generate(got)
+	drbg.generate(got)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("hmacDRBG.generate() = %x, but want %x", got, want)
+	}
+}
+
+// TestDeriveECDSAKey checks deriveECDSAKey against the P-256 test vectors of
+// filippo.io/keygen, which are copied from its testdata/ecdsa.json. The last
+// vector exercises the rejection sampling loop.
+func TestDeriveECDSAKey(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/keygen-ecdsa-p256.json")
+	if err != nil {
+		t.Fatalf("failed to read test vectors: %s", err)
+	}
+
+	var vectors []struct {
+		Curve string `json:"curve"`
+		Seed  []byte `json:"seed"`
+		PKCS8 []byte `json:"private_key_pkcs8"` //nolint:tagliatelle
+	}
+
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatalf("failed to parse test vectors: %s", err)
+	}
+
+	if len(vectors) == 0 {
+		t.Fatal("found no test vectors")
+	}
+
+	for _, tc := range vectors {
+		parsed, err := x509.ParsePKCS8PrivateKey(tc.PKCS8)
+		if err != nil {
+			t.Fatalf("failed to parse private key for seed %x: %s", tc.Seed, err)
+		}
+
+		want, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok {
+			t.Fatalf("private key for seed %x has unexpected type %T", tc.Seed, parsed)
+		}
+
+		wantECDH, err := want.ECDH()
+		if err != nil {
+			t.Fatalf("failed to convert private key for seed %x: %s", tc.Seed, err)
+		}
+
+		got, err := deriveECDSAKey(tc.Seed)
+		if err != nil {
+			t.Errorf("deriveECDSAKey(%x) = %#v, but want no error", tc.Seed, err)
+		} else if !got.Equal(wantECDH) {
+			t.Errorf("deriveECDSAKey(%x) = %x, but want %x", tc.Seed, got.Bytes(), wantECDH.Bytes())
+		}
+	}
+}
+
+// TestDeriveKeys checks the whole derivation from a seed. The expected SPKIs
+// were computed with filippo.io/keygen.ECDSA rather than with deriveKeys.
+func TestDeriveKeys(t *testing.T) {
+	t.Parallel()
+
+	sequential := make([]byte, seedLen)
+	for i := range sequential {
+		sequential[i] = byte(i)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		seed     []byte
+		wantSPKI string
+		wantErr  string
+	}{
+		{
+			name:     "empty",
+			seed:     nil,
+			wantSPKI: "",
+			wantErr:  "empty seed",
+		},
+		{
+			name:     "zeros",
+			seed:     make([]byte, seedLen),
+			wantSPKI: "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEHm9VJxsjVA4jUWTJirhJFLJnpHLl24Mib2kqf38/A76cCUdoYipPZlhEl7WKde+4w9vG13ecEcRN3dCaiyfR0w==",
+			wantErr:  "",
+		},
+		{
+			name:     "sequential",
+			seed:     sequential,
+			wantSPKI: "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEJYiFX1XUJTOISfsKZeBUvOun+vC8k8a8XclPz5nki3AJNivyNffzHlczWPzCYGKmfS8oGZkHhQmSq+mp7ItrOA==",
+			wantErr:  "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := deriveKeys(tc.seed)
+			if tc.wantErr != "" { //nolint:nestif
+				if err == nil {
+					t.Errorf("deriveKeys(%x) = %#v, but want error %q", tc.seed, got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("deriveKeys(%x) = %#v, but want error %q", tc.seed, err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("deriveKeys(%x) = %#v, but want no error", tc.seed, err)
+				}
+
+				gotSPKI := base64.StdEncoding.EncodeToString(got.SPKI)
+				if gotSPKI != tc.wantSPKI {
+					t.Errorf("deriveKeys(%x) has SPKI %s, but want %s", tc.seed, gotSPKI, tc.wantSPKI)
+				}
+			}
+		})
+	}
+}
+
+// TestDeriveKeysVector checks the derivation end to end, from a seed file to
+// the public key file and log ID which Sunlight would use for it, so that it
+// covers the HKDF salt and info strings as well as key generation. The expected
+// values were computed by following Sunlight's derivation with crypto/hkdf and
+// filippo.io/keygen.ECDSA, independently of deriveKeys.
+func TestDeriveKeysVector(t *testing.T) {
+	t.Parallel()
+
+	seed, err := os.ReadFile("testdata/vector.seed")
+	if err != nil {
+		t.Fatalf("failed to read seed: %s", err)
+	}
+
+	wantPEM, err := os.ReadFile("testdata/vector.pem")
+	if err != nil {
+		t.Fatalf("failed to read public key: %s", err)
+	}
+
+	keys, err := deriveKeys(seed)
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	if !bytes.Equal(keys.publicKeyPEM(), wantPEM) {
+		t.Errorf("publicKeyPEM() = %q, but want %q", keys.publicKeyPEM(), wantPEM)
+	}
+
+	wantLogID := "USKVAqQqKHP1HgNZjFFJnYoh/Bd7l15zvYpYlqqSJHw="
+	if keys.logIDBase64() != wantLogID {
+		t.Errorf("logIDBase64() = %q, but want %q", keys.logIDBase64(), wantLogID)
+	}
+
+	wantWitnessKey := "5+oFxVpUIMOoNY+veM0ngRmgE+6tAqcBKtluGLaXDMs="
+	if got := base64.StdEncoding.EncodeToString(keys.WitnessKey); got != wantWitnessKey {
+		t.Errorf("deriveKeys() has witness key %s, but want %s", got, wantWitnessKey)
+	}
+}
+
+func TestPublicKeyPEM(t *testing.T) {
+	t.Parallel()
+
+	keys, err := deriveKeys(make([]byte, seedLen))
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	block, rest := pem.Decode(keys.publicKeyPEM())
+	if block == nil || len(rest) != 0 {
+		t.Fatalf("publicKeyPEM() = %q, but want a single PEM block", keys.publicKeyPEM())
+	}
+
+	if block.Type != "PUBLIC KEY" {
+		t.Errorf("publicKeyPEM() has type %q, but want %q", block.Type, "PUBLIC KEY")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse public key: %s", err)
+	}
+
+	if !keys.PublicKey.Equal(pub) {
+		t.Errorf("publicKeyPEM() contains %#v, but want %#v", pub, keys.PublicKey)
+	}
+}
+
+func TestVerifierKey(t *testing.T) {
+	t.Parallel()
+
+	keys, err := deriveKeys(make([]byte, seedLen))
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		logName string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "happy path",
+			logName: "test.tld/shard1",
+			want:    "test.tld/shard1+950faaa0+AUEh7CYNsozpJNti+xF88ZfAkoKdxXv7YJdbUye3arGL",
+			wantErr: "",
+		},
+		{
+			name:    "invalid name",
+			logName: "test.tld/shard 1",
+			want:    "",
+			wantErr: "encoding verifier key",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := keys.verifierKey(tc.logName)
+			if tc.wantErr != "" { //nolint:nestif
+				if err == nil {
+					t.Errorf("verifierKey(%q) = %q, but want error %q", tc.logName, got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("verifierKey(%q) = %#v, but want error %q", tc.logName, err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("verifierKey(%q) = %#v, but want no error", tc.logName, err)
+				}
+
+				if got != tc.want {
+					t.Errorf("verifierKey(%q) = %q, but want %q", tc.logName, got, tc.want)
+				}
+
+				verifier, err := note.NewVerifier(got)
+				if err != nil {
+					t.Fatalf("failed to parse verifier key %q: %s", got, err)
+				}
+
+				if verifier.Name() != tc.logName {
+					t.Errorf("verifier key has name %q, but want %q", verifier.Name(), tc.logName)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckLogID(t *testing.T) {
+	t.Parallel()
+
+	keys, err := deriveKeys(make([]byte, seedLen))
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		logID   string
+		wantErr string
+	}{
+		{
+			name:    "not configured",
+			logID:   "",
+			wantErr: "",
+		},
+		{
+			name:    "mismatch",
+			logID:   "sZlxFIOdM3dBBnXGvX8+VL7RkE1XTG4OSEyOXfF1mTI=",
+			wantErr: "does not match expected log ID",
+		},
+		{
+			name:    "happy path",
+			logID:   "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0=",
+			wantErr: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := keys.checkLogID(tc.logID)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("checkLogID(%q) = success, but want error %q", tc.logID, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("checkLogID(%q) = %#v, but want error %q", tc.logID, err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("checkLogID(%q) = %#v, but want success", tc.logID, err)
+			}
+		})
+	}
+}
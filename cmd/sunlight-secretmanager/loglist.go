@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// logList is a subset of the v3 CT log list schema used by Chrome and Apple.
+// https://www.gstatic.com/ct/log_list/v3/log_list_schema.json
+type logList struct {
+	Operators []logListOperator `json:"operators"`
+}
+
+// logListOperator is a single log operator in a log list. RFC 6962 logs and
+// static-ct-api (tiled) logs are listed separately.
+type logListOperator struct {
+	Name      string         `json:"name"`
+	Email     []string       `json:"email"`
+	Logs      []logListEntry `json:"logs"`
+	TiledLogs []logListEntry `json:"tiled_logs"` //nolint:tagliatelle
+}
+
+// logListEntry is a single log in a log list. RFC 6962 logs have a URL, while
+// tiled logs have a submission URL and a monitoring URL.
+type logListEntry struct {
+	Description   string `json:"description"`
+	LogID         string `json:"log_id"` //nolint:tagliatelle
+	Key           string `json:"key"`
+	URL           string `json:"url,omitempty"`
+	SubmissionURL string `json:"submission_url,omitempty"` //nolint:tagliatelle
+	MonitoringURL string `json:"monitoring_url,omitempty"` //nolint:tagliatelle
+	// MMD is the log's Maximum Merge Delay in seconds.
+	MMD int `json:"mmd"`
+	// TemporalInterval is only set for logs which shard by certificate expiry.
+	TemporalInterval *temporalInterval `json:"temporal_interval,omitempty"` //nolint:tagliatelle
+}
+
+// temporalInterval is the range of certificate expiry dates accepted by a log,
+// as RFC 3339 timestamps.
+type temporalInterval struct {
+	StartInclusive string `json:"start_inclusive"` //nolint:tagliatelle
+	EndExclusive   string `json:"end_exclusive"`   //nolint:tagliatelle
+}
+
+// loadLogList reads a v3 log list JSON file, such as Chrome's log_list.json or
+// Apple's current_log_list.json.
+func loadLogList(path string) (*logList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading log list %q: %w", path, err)
+	}
+
+	var list logList
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log list %q: %w", path, err)
+	}
+
+	return &list, nil
+}
+
+// entries returns every log in the list, regardless of operator or type.
+func (ll *logList) entries() []logListEntry {
+	var entries []logListEntry
+	for _, op := range ll.Operators {
+		entries = append(entries, op.Logs...)
+		entries = append(entries, op.TiledLogs...)
+	}
+
+	return entries
+}
+
+// check cross-checks the keys derived for a configured log against the list.
+// It returns an error if the log is listed with a different key or log ID, or
+// if the derived key belongs to some other log in the list. Logs which are not
+// listed at all pass the check, since new logs are not listed yet.
+func (ll *logList) check(lc logConfig, keys *logKeys) error {
+	key := keys.spkiBase64()
+	logID := keys.logIDBase64()
+
+	var errs []error
+	for _, entry := range ll.entries() {
+		if entry.matches(lc) {
+			if entry.Key != key {
+				errs = append(errs, fmt.Errorf("log list entry %q has key %s, but derived key is %s", entry.Description, entry.Key, key))
+			}
+
+			if entry.LogID != logID {
+				errs = append(errs, fmt.Errorf("log list entry %q has log ID %s, but derived log ID is %s", entry.Description, entry.LogID, logID))
+			}
+		} else if entry.Key == key || entry.LogID == logID {
+			errs = append(errs, fmt.Errorf("derived key with log ID %s belongs to a different log in the log list: %q", logID, entry.Description))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// matches returns true if the log list entry describes the configured log,
+// either because one of their URLs match or because the entry's description
+// is the configured Description.
+func (e logListEntry) matches(lc logConfig) bool {
+	if lc.Description != "" && e.Description == lc.Description {
+		return true
+	}
+
+	configured := []string{lc.Name, lc.SubmissionPrefix, lc.MonitoringPrefix}
+	listed := []string{e.URL, e.SubmissionURL, e.MonitoringURL}
+
+	for _, c := range configured {
+		for _, l := range listed {
+			if c != "" && l != "" && normalizeLogURL(c) == normalizeLogURL(l) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// normalizeLogURL strips the scheme and trailing slash from a log URL, so that
+// URLs from log lists can be compared with a log's Name, which has neither.
+func normalizeLogURL(u string) string {
+	u = strings.TrimPrefix(u, "https://")
+	u = strings.TrimPrefix(u, "http://")
+
+	return strings.TrimSuffix(u, "/")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLogList(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		input       string
+		wantEntries int
+		wantErr     string
+	}{
+		{
+			name:        "invalid path",
+			input:       "",
+			wantEntries: 0,
+			wantErr:     "reading log list",
+		},
+		{
+			name:        "invalid json",
+			input:       "invalid.yaml",
+			wantEntries: 0,
+			wantErr:     "parsing log list",
+		},
+		{
+			name:        "happy path",
+			input:       "loglist.json",
+			wantEntries: 3,
+			wantErr:     "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := loadLogList(filepath.Join("testdata", tc.input))
+			if tc.wantErr != "" { //nolint:nestif
+				if err == nil {
+					t.Errorf("loadLogList() = %#v, but want %q", got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("loadLogList() = %q, but want %q", err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("loadLogList() = %q, but want success", err)
+				}
+
+				if len(got.entries()) != tc.wantEntries {
+					t.Errorf("loadLogList() has %d entries, but want %d", len(got.entries()), tc.wantEntries)
+				}
+			}
+		})
+	}
+}
+
+func TestLogListCheck(t *testing.T) {
+	t.Parallel()
+
+	list, err := loadLogList(filepath.Join("testdata", "loglist.json"))
+	if err != nil {
+		t.Fatalf("loadLogList() = %q, but want success", err)
+	}
+
+	sequential := make([]byte, seedLen)
+	for i := range sequential {
+		sequential[i] = byte(i)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		conf    logConfig
+		seed    []byte
+		wantErr string
+	}{
+		{
+			name:    "matched by name",
+			conf:    logConfig{Name: "test.tld/shard1"}, //nolint:exhaustruct
+			seed:    make([]byte, seedLen),
+			wantErr: "",
+		},
+		{
+			name:    "matched by submission prefix",
+			conf:    logConfig{Name: "shard1.test.tld", SubmissionPrefix: "https://test.tld/shard1"}, //nolint:exhaustruct
+			seed:    make([]byte, seedLen),
+			wantErr: "",
+		},
+		{
+			name:    "matched by description",
+			conf:    logConfig{Name: "renamed.tld/shard2", Description: "Test 'Shard2'"}, //nolint:exhaustruct
+			seed:    sequential,
+			wantErr: "",
+		},
+		{
+			name:    "not listed",
+			conf:    logConfig{Name: "test.tld/shard3"}, //nolint:exhaustruct
+			seed:    bytes.Repeat([]byte{0xff}, seedLen),
+			wantErr: "",
+		},
+		{
+			name:    "listed with different key",
+			conf:    logConfig{Name: "test.tld/shard1"}, //nolint:exhaustruct
+			seed:    bytes.Repeat([]byte{0xff}, seedLen),
+			wantErr: "log list entry \"Test 'Shard1'\" has key",
+		},
+		{
+			name:    "key of another log",
+			conf:    logConfig{Name: "test.tld/shard3"}, //nolint:exhaustruct
+			seed:    make([]byte, seedLen),
+			wantErr: "belongs to a different log in the log list: \"Test 'Shard1'\"",
+		},
+		{
+			name:    "swapped keys",
+			conf:    logConfig{Name: "test.tld/shard1"}, //nolint:exhaustruct
+			seed:    sequential,
+			wantErr: "belongs to a different log in the log list: \"Test 'Shard2'\"",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := deriveKeys(tc.seed)
+			if err != nil {
+				t.Fatalf("deriveKeys() = %#v, but want no error", err)
+			}
+
+			err = list.check(tc.conf, keys)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("check(%q) = success, but want error %q", tc.conf.Name, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("check(%q) = %q, but want error %q", tc.conf.Name, err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("check(%q) = %q, but want success", tc.conf.Name, err)
+			}
+		})
+	}
+}
@@ -1,12 +1,22 @@
 // sunlight-secretmanager ensures that Sunlight CT Log instances have access to
 // their secret key material. In particular, it reads the log's config file,
 // extracts the paths at which each log in that config expects to find its
-// key material, fetches the corresponding secrets from the AWS Secrets Manager
-// API, and writes the secrets to the expected location.
+// key material, fetches the corresponding secrets from AWS Secrets Manager (or
+// HashiCorp Vault), and writes the secrets and their derived public keys to
+// the expected locations.
 //
 // Usage:
 //
-//	sunlight-secretmanager -config /path/to/config.yaml
+//	sunlight-secretmanager [write] -config /path/to/config.yaml [-log-list /path/to/log_list.json] [-verify-checkpoint] [-timeout 10m]
+//	sunlight-secretmanager pubkey -config /path/to/config.yaml -log test.tld/shard1 [-format pem|der|jwk|logid]
+//	sunlight-secretmanager export-loglist -config /path/to/config.yaml -operator Name [-email a@b.c] [-mmd 86400] [-tiled]
+//	sunlight-secretmanager validate -config /path/to/config.yaml
+//
+// The write command is the default, and materializes the key material of every
+// log in the config. The pubkey command prints the public key of a single log,
+// and the export-loglist command prints a CT log list fragment describing every
+// log, without writing anything to disk. The validate command checks the whole
+// config file for problems without talking to Secrets Manager.
 package main
 
 import (
@@ -16,11 +26,8 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
-
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 )
 
 // tmpfsMagic is the magic number used to indicate that a unix filesystem is
@@ -30,11 +37,38 @@ import (
 const tmpfsMagic = 0x1021994
 
 func main() {
-	flagset := flag.NewFlagSet("sunlight-secretmanager", flag.ContinueOnError)
+	// For compatibility with deployments that predate subcommands, invoking the
+	// tool with only flags runs the write command.
+	command, args := "write", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "write":
+		writeCommand(args)
+	case "pubkey":
+		pubkeyCommand(args)
+	case "export-loglist":
+		exportLogListCommand(args)
+	case "validate":
+		validateCommand(args)
+	default:
+		log.Fatalf("Unknown command %q: must be one of write, pubkey, export-loglist, validate", command)
+	}
+}
+
+// writeCommand fetches (or creates) the seed of every log in the config, checks
+// the keys derived from them, and writes the seeds and public keys to disk.
+func writeCommand(args []string) {
+	flagset := flag.NewFlagSet("sunlight-secretmanager write", flag.ContinueOnError)
 	configFlag := flagset.String("config", "", "Path to YAML config file")
+	logListFlag := flagset.String("log-list", "", "Path to a v3 CT log list JSON file to cross-check derived keys against")
+	verifyCheckpointFlag := flagset.Bool("verify-checkpoint", false, "Refuse to write seeds which don't verify the log's latest checkpoint, if it has one")
 	fileSystemFlag := flagset.Int64("filesystem", tmpfsMagic, "OS Filesystem constant to enforce writing to. Defaults to Linux tmpfs")
+	timeoutFlag := flagset.Duration("timeout", 10*time.Minute, "Time after which to give up on fetching and creating seeds")
 
-	err := flagset.Parse(os.Args[1:])
+	err := flagset.Parse(args)
 	if err != nil {
 		log.Fatalf("Error parsing flags: %s", err)
 	}
@@ -44,44 +78,201 @@ func main() {
 		log.Fatalf("Error loading config: %s", err)
 	}
 
-	ctx := context.Background()
+	var logList *logList
+	if *logListFlag != "" {
+		logList, err = loadLogList(*logListFlag)
+		if err != nil {
+			log.Fatalf("Error loading log list: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
 
-	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(os.Getenv("AWS_PROFILE")))
+	backend, err := newBackend(ctx, config.SecretManager)
 	if err != nil {
-		log.Fatalf("Error loading default AWS config: %s", err)
+		log.Fatalf("Error creating seed backend: %s", err)
+	}
+
+	// Every log's seed is fetched up front, so that all of their errors are
+	// reported, not just the first.
+	fetched := backend.fetchAll(ctx, config.Logs)
+	for _, logConf := range config.Logs {
+		err := fetched[logConf.SecretID].Err
+		if err != nil && !errors.Is(err, errSecretNotFound) {
+			log.Printf("Error fetching seed for log %q: %v", logConf.Name, err)
+		}
+	}
+
+	// Nothing is written until the seeds of all logs have been checked, so
+	// that a bad seed for one log doesn't leave the others half written.
+	type checkedLog struct {
+		conf    logConfig
+		seed    []byte
+		keys    *logKeys
+		store   seedStore
+		version seedVersion
+		vkey    string
 	}
 
-	smClient := secretsmanager.NewFromConfig(cfg)
+	checked := make([]checkedLog, 0, len(config.Logs))
+	seeds := make(seedSet)
 
 	for _, logConf := range config.Logs {
-		seed, err := getOrCreateSeed(ctx, filepath.Base(logConf.Secret), logConf.Inception, smClient)
+		store, err := backend.store(logConf)
 		if err != nil {
 			log.Fatalf("Error getting seed for log %q: %v", logConf.Name, err)
 		}
 
-		err = writeFile(logConf.Secret, seed, *fileSystemFlag)
+		seed, version, err := getOrCreateSeed(ctx, logConf.SecretID, logConf, config, store, fetched[logConf.SecretID], time.Now())
 		if err != nil {
-			log.Fatalf("Error persisting seed for log %q: %v", logConf.Name, err)
+			log.Fatalf("Error getting seed for log %q: %v", logConf.Name, err)
 		}
+
+		err = config.SecretManager.checkSeed(seed)
+		if err != nil {
+			log.Fatalf("Invalid seed for log %q: %v", logConf.Name, err)
+		}
+
+		err = seeds.add(logConf.Name, seed)
+		if err != nil {
+			log.Fatalf("Invalid seed for log %q: %v", logConf.Name, err)
+		}
+
+		keys, err := deriveKeys(seed)
+		if err != nil {
+			log.Fatalf("Error deriving keys for log %q: %v", logConf.Name, err)
+		}
+
+		err = keys.checkLogID(logConf.LogID)
+		if err != nil {
+			log.Fatalf("Error checking seed for log %q: %v", logConf.Name, err)
+		}
+
+		if logList != nil {
+			err = logList.check(logConf, keys)
+			if err != nil {
+				log.Fatalf("Error checking seed for log %q against log list: %v", logConf.Name, err)
+			}
+		}
+
+		if *verifyCheckpointFlag {
+			path := logConf.checkpointPath()
+			if path == "" {
+				log.Fatalf("Error verifying checkpoint for log %q: neither checkpoint nor localdirectory is configured", logConf.Name)
+			}
+
+			verified, err := verifyCheckpoint(path, logConf.Name, keys)
+			if err != nil {
+				log.Fatalf("Error verifying checkpoint for log %q: %v", logConf.Name, err)
+			}
+
+			if !verified {
+				log.Printf("No checkpoint found for log %q at %q, assuming it has not started yet", logConf.Name, path)
+			}
+		}
+
+		_, err = retryTransient(ctx, transientRetryDelays, func() (struct{}, error) {
+			return struct{}{}, store.CheckSafety(ctx, logConf.SecretID, version)
+		})
+		if err != nil {
+			log.Fatalf("Error checking secret for log %q: %v", logConf.Name, err)
+		}
+
+		vkey, err := keys.verifierKey(logConf.Name)
+		if err != nil {
+			log.Fatalf("Error encoding verifier key for log %q: %v", logConf.Name, err)
+		}
+
+		checked = append(checked, checkedLog{conf: logConf, seed: seed, keys: keys, store: store, version: version, vkey: vkey})
+	}
+
+	// A pinned version is permanent, so seeds are only pinned once all of
+	// them have passed every check.
+	for _, c := range checked {
+		_, err = retryTransient(ctx, transientRetryDelays, func() (struct{}, error) {
+			return struct{}{}, c.store.Pin(ctx, c.conf.SecretID, c.version)
+		})
+		if err != nil {
+			log.Fatalf("Error pinning seed for log %q: %v", c.conf.Name, err)
+		}
+	}
+
+	for _, c := range checked {
+		err = writeFile(c.conf.Secret, c.seed, *fileSystemFlag)
+		if err != nil {
+			log.Fatalf("Error persisting seed for log %q: %v", c.conf.Name, err)
+		}
+
+		err = writeFile(c.conf.publicKeyPath(), c.keys.publicKeyPEM(), *fileSystemFlag)
+		if err != nil {
+			log.Fatalf("Error persisting public key for log %q: %v", c.conf.Name, err)
+		}
+
+		log.Printf("Wrote key material for log %q from %s with checkpoint verifier key %s", c.conf.Name, c.version.Source, c.vkey)
 	}
 }
 
-func getOrCreateSeed(ctx context.Context, id string, inception string, smClient SecretsManager) ([]byte, error) {
-	seed, err := fetchSeed(ctx, smClient, id)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching seed: %w", err)
+// getOrCreateSeed takes the seed with the given ID as fetched from the log's
+// store, and returns it along with the version which holds it. If the seed is
+// empty or the secret doesn't exist, it creates a new one, but not in a
+// read-only replica, only if the seed creation policy allows it at time now
+// given the log's Inception date, and only if Sunlight's checkpoints database
+// (if any) doesn't know the log already. Either way, it makes sure that the
+// seed is the pinned version of the secret, or could be pinned, but leaves
+// pinning it to the caller. Requests to the store which fail with transient
+// errors are retried.
+func getOrCreateSeed(ctx context.Context, id string, logConf logConfig, conf *config, store seedStore, fetched seedFetch, now time.Time) ([]byte, seedVersion, error) {
+	seed, version, err := fetched.Seed, fetched.Version, fetched.Err
+
+	// Secrets are normally created empty by Terraform, but we can also create
+	// them from scratch.
+	if err != nil && !errors.Is(err, errSecretNotFound) {
+		return nil, seedVersion{}, fmt.Errorf("error fetching seed: %w", err) //nolint:exhaustruct
 	}
 
 	if len(seed) == 0 {
-		if time.Now().Format(time.DateOnly) != inception {
-			return nil, errors.New("log has empty seed, but today is not the Inception date")
+		// A replica only serves us when the primary is down, and may simply
+		// lag behind it, so its secret being empty proves nothing.
+		if version.Replica {
+			return nil, seedVersion{}, fmt.Errorf("log has empty seed in replica %s, but seeds can only be created in the primary", version.Source) //nolint:exhaustruct
+		}
+
+		if conf.Checkpoints != "" {
+			known, err := checkpointsDBHasLog(ctx, conf.Checkpoints, logConf)
+			if err != nil {
+				return nil, seedVersion{}, fmt.Errorf("error checking checkpoints database: %w", err) //nolint:exhaustruct
+			}
+
+			if known {
+				return nil, seedVersion{}, errors.New("log has empty seed, but the checkpoints database already has a checkpoint for it") //nolint:exhaustruct
+			}
+		}
+
+		err = conf.SecretManager.checkSeedCreation(logConf.Inception, now)
+		if err != nil {
+			return nil, seedVersion{}, fmt.Errorf("log has empty seed, but creating one is not allowed: %w", err) //nolint:exhaustruct
 		}
 
-		seed, err = createSeed(ctx, smClient, id)
+		// Retrying is safe because stores keep the first seed stored.
+		fresh := newSeed()
+		seed, err = retryTransient(ctx, transientRetryDelays, func() ([]byte, error) {
+			stored, v, err := store.CreateIfAbsent(ctx, id, fresh)
+			version = v
+
+			return stored, err
+		})
 		if err != nil {
-			return nil, fmt.Errorf("error creating seed: %w", err)
+			return nil, seedVersion{}, fmt.Errorf("error creating seed: %w", err) //nolint:exhaustruct
 		}
 	}
 
-	return seed, nil
+	_, err = retryTransient(ctx, transientRetryDelays, func() (struct{}, error) {
+		return struct{}{}, store.CheckPin(ctx, id, version)
+	})
+	if err != nil {
+		return nil, seedVersion{}, fmt.Errorf("error checking seed version: %w", err) //nolint:exhaustruct
+	}
+
+	return seed, version, nil
 }
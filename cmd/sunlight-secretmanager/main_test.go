@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+func TestGetOrCreateSeed(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.June, 15, 23, 59, 59, 0, time.UTC)
+	today := now.Format(time.DateOnly)
+	nextWeek := now.AddDate(0, 0, 7).Format(time.DateOnly)
+	checkpointsDB := createCheckpointsDB(t, "test.tld/shard1")
+
+	for _, tc := range []struct {
+		name          string
+		id            string
+		logName       string
+		inception     string
+		checkpointsDB string
+		seedCreation  string
+		wantSeed      []byte
+		wantErr       string
+	}{
+		{
+			name:          "fetch error",
+			id:            "missing",
+			logName:       "test.tld/shard1",
+			inception:     today,
+			checkpointsDB: "",
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "error fetching seed",
+		},
+		{
+			name:          "existing seed",
+			id:            "real",
+			logName:       "test.tld/shard1",
+			inception:     "2024-08-07",
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      []byte("hello world"),
+			wantErr:       "",
+		},
+		{
+			name:          "changed seed",
+			id:            "changed",
+			logName:       "test.tld/shard1",
+			inception:     "2024-08-07",
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "the seed has changed since it was first used",
+		},
+		{
+			name:          "empty seed after inception",
+			id:            "terraform",
+			logName:       "test.tld/shard2",
+			inception:     "2024-08-07",
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "inception date 2024-08-07 has passed",
+		},
+		{
+			name:          "empty seed for known log",
+			id:            "terraform",
+			logName:       "test.tld/shard1",
+			inception:     today,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "checkpoints database already has a checkpoint",
+		},
+		{
+			name:          "empty seed before inception window",
+			id:            "terraform",
+			logName:       "test.tld/shard2",
+			inception:     nextWeek,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "only allows creating seeds on the inception date",
+		},
+		{
+			name:          "empty seed before inception",
+			id:            "terraform",
+			logName:       "test.tld/shard2",
+			inception:     nextWeek,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  seedCreationBeforeInception,
+			wantSeed:      nil,
+			wantErr:       "",
+		},
+		{
+			name:          "empty seed with creation disabled",
+			id:            "terraform",
+			logName:       "test.tld/shard2",
+			inception:     today,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  seedCreationNever,
+			wantSeed:      nil,
+			wantErr:       "forbids creating seeds",
+		},
+		{
+			name:          "secret scheduled for deletion",
+			id:            "deleted",
+			logName:       "test.tld/shard2",
+			inception:     today,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "secret is scheduled for deletion",
+		},
+		{
+			name:          "secret without versions",
+			id:            "terraform",
+			logName:       "test.tld/shard2",
+			inception:     today,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "",
+		},
+		{
+			name:          "nonexistent secret",
+			id:            "absent",
+			logName:       "test.tld/shard2",
+			inception:     today,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "",
+		},
+		{
+			name:          "nonexistent secret after inception",
+			id:            "absent",
+			logName:       "test.tld/shard2",
+			inception:     "2024-08-07",
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       "inception date 2024-08-07 has passed",
+		},
+		{
+			name:          "secret without a value",
+			id:            "empty",
+			logName:       "test.tld/shard2",
+			inception:     today,
+			checkpointsDB: checkpointsDB,
+			seedCreation:  "",
+			wantSeed:      nil,
+			wantErr:       `secret "empty" has neither SecretBinary nor SecretString`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Each case gets its own fake, since creating a seed changes it.
+			testSM := &fakeSecretsManager{}                                 //nolint:exhaustruct
+			logConf := logConfig{Name: tc.logName, Inception: tc.inception} //nolint:exhaustruct
+			conf := &config{
+				Checkpoints:   tc.checkpointsDB,
+				SecretManager: secretManagerConfig{SeedCreation: tc.seedCreation}, //nolint:exhaustruct
+				Logs:          []logConfig{logConf},
+			}
+
+			clients := []regionalClient{{Region: "us-west-2", Client: testSM}}
+			fetched := fetchSeeds(t.Context(), clients, []string{tc.id}, "")
+
+			got, _, err := getOrCreateSeed(t.Context(), tc.id, logConf, conf, newAWSStore(roleClients{Regions: clients, STS: nil}, conf.SecretManager, logConf, ""), fetched[tc.id], now)
+			if tc.wantErr != "" { //nolint:nestif
+				if err == nil {
+					t.Errorf("getOrCreateSeed(%q) = %#v, but want error %q", tc.id, got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("getOrCreateSeed(%q) = %q, but want error %q", tc.id, err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("getOrCreateSeed(%q) = %q, but want success", tc.id, err)
+				} else if tc.wantSeed != nil && !bytes.Equal(got, tc.wantSeed) {
+					t.Errorf("getOrCreateSeed(%q) = %#v, but want %#v", tc.id, got, tc.wantSeed)
+				} else if len(got) != seedLen && tc.wantSeed == nil {
+					t.Errorf("getOrCreateSeed(%q) created a seed of length %d, but want %d", tc.id, len(got), seedLen)
+				}
+			}
+		})
+	}
+}
+
+// racingSecretsManager simulates several hosts initializing the same empty or
+// nonexistent secret at once. It holds every CreateSecret and PutSecretValue
+// request until all hosts have sent one, so that they all saw the secret empty,
+// and then applies them one at a time like Secrets Manager would.
+type racingSecretsManager struct {
+	fakeSecretsManager
+
+	exists  bool
+	hosts   int
+	arrived atomic.Int32
+	ready   chan struct{}
+	token   string
+}
+
+func newRacingSecretsManager(exists bool, hosts int) *racingSecretsManager {
+	return &racingSecretsManager{ //nolint:exhaustruct
+		exists: exists,
+		hosts:  hosts,
+		ready:  make(chan struct{}),
+	}
+}
+
+// wait blocks until every host has sent a request which stores a seed.
+func (sm *racingSecretsManager) wait() {
+	if int(sm.arrived.Add(1)) == sm.hosts {
+		close(sm.ready)
+	}
+
+	<-sm.ready
+}
+
+func (sm *racingSecretsManager) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	seed, ok := sm.lookup(*params.SecretId)
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")} //nolint:exhaustruct
+	}
+
+	return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+		Name:         params.SecretId,
+		SecretBinary: seed,
+		VersionId:    aws.String(seedRequestToken(*params.SecretId)),
+	}, nil
+}
+
+func (sm *racingSecretsManager) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	return batchGetSecretValue(ctx, sm, params)
+}
+
+func (sm *racingSecretsManager) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	if _, ok := sm.lookup(*params.SecretId); ok {
+		return sm.fakeSecretsManager.DescribeSecret(ctx, params, optFns...)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if !sm.exists {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")} //nolint:exhaustruct
+	}
+
+	return &secretsmanager.DescribeSecretOutput{ //nolint:exhaustruct
+		Name: params.SecretId,
+	}, nil
+}
+
+func (sm *racingSecretsManager) CreateSecret(_ context.Context, params *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	sm.wait()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.exists {
+		return nil, &types.ResourceExistsException{Message: aws.String("The operation failed because the secret already exists.")} //nolint:exhaustruct
+	}
+
+	sm.exists = true
+	sm.stored = map[string][]byte{*params.Name: params.SecretBinary}
+
+	return &secretsmanager.CreateSecretOutput{ //nolint:exhaustruct
+		Name:      params.Name,
+		VersionId: params.ClientRequestToken,
+	}, nil
+}
+
+func (sm *racingSecretsManager) PutSecretValue(_ context.Context, params *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	sm.wait()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// Secrets Manager refuses to reuse the token of an existing version for a
+	// different value, but if the tokens differ, the later value would win.
+	if sm.token != "" && *params.ClientRequestToken == sm.token &&
+		!bytes.Equal(sm.stored[*params.SecretId], params.SecretBinary) {
+		return nil, &types.ResourceExistsException{Message: aws.String("You can't modify an existing version.")} //nolint:exhaustruct
+	}
+
+	sm.token = *params.ClientRequestToken
+	sm.stored = map[string][]byte{*params.SecretId: params.SecretBinary}
+
+	return &secretsmanager.PutSecretValueOutput{ //nolint:exhaustruct
+		Name:      params.SecretId,
+		VersionId: params.ClientRequestToken,
+	}, nil
+}
+
+func TestGetOrCreateSeedRace(t *testing.T) {
+	t.Parallel()
+
+	const hosts = 5
+
+	now := time.Date(2025, time.June, 15, 23, 59, 59, 0, time.UTC)
+	today := now.Format(time.DateOnly)
+	logConf := logConfig{Name: "test.tld/shard1", Inception: today} //nolint:exhaustruct
+	conf := &config{Logs: []logConfig{logConf}}                     //nolint:exhaustruct
+
+	for _, tc := range []struct {
+		name   string
+		exists bool
+	}{
+		{name: "put", exists: true},
+		{name: "create", exists: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSM := newRacingSecretsManager(tc.exists, hosts)
+
+			var wg sync.WaitGroup
+			seeds := make([][]byte, hosts)
+			errs := make([]error, hosts)
+
+			for i := range hosts {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					clients := []regionalClient{{Region: "us-west-2", Client: testSM}}
+					fetched := fetchSeeds(t.Context(), clients, []string{"shard1"}, "")
+
+					seeds[i], _, errs[i] = getOrCreateSeed(t.Context(), "shard1", logConf, conf, newAWSStore(roleClients{Regions: clients, STS: nil}, conf.SecretManager, logConf, ""), fetched["shard1"], now)
+				}()
+			}
+
+			wg.Wait()
+
+			stored, _ := testSM.lookup("shard1")
+			for i := range hosts {
+				if errs[i] != nil {
+					t.Errorf("host %d: getOrCreateSeed() = %q, but want success", i, errs[i])
+				} else if !bytes.Equal(seeds[i], stored) {
+					t.Errorf("host %d: getOrCreateSeed() = %x, but the stored seed is %x", i, seeds[i], stored)
+				}
+			}
+		})
+	}
+}
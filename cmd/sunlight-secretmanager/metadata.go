@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// The tags which createSeed puts on every secret it creates, in addition to
+// the configured Tags.
+const (
+	logNameTag   = "sunlight:log-name"
+	shortNameTag = "sunlight:short-name"
+	inceptionTag = "sunlight:inception"
+	logIDTag     = "sunlight:log-id"
+)
+
+// replicationPollInterval is how often waitForReplication checks the status
+// of a new secret's replicas, and replicationTimeout is how long it waits for
+// them to be in sync.
+const (
+	replicationPollInterval = 5 * time.Second
+	replicationTimeout      = 5 * time.Minute
+)
+
+// replicaRegionConfig is a region to which created secrets are replicated.
+type replicaRegionConfig struct {
+	// Region is the AWS region of the replica, such as "us-east-2".
+	Region string
+	// KmsKeyID is the ID, ARN or alias of the KMS key in Region with which
+	// the replica is encrypted. It is optional, and defaults to the AWS
+	// managed key.
+	KmsKeyID string
+}
+
+// secretMetadata is the metadata with which createSeed creates a secret.
+type secretMetadata struct {
+	// KmsKeyID is the KMS key with which the secret is encrypted, or the
+	// empty string for the AWS managed key.
+	KmsKeyID string
+	// Description is the secret's description, which may be empty.
+	Description string
+	// Tags are the secret's tags. The log ID tag is added by createSeed.
+	Tags map[string]string
+	// ReplicaRegions are the regions to which the secret is replicated.
+	ReplicaRegions []replicaRegionConfig
+	// Encoding is the encoding with which the seed is stored.
+	Encoding string
+}
+
+// secretMetadata returns the metadata with which to create the secret of the
+// given log.
+func (smc secretManagerConfig) secretMetadata(lc logConfig) secretMetadata {
+	tags := maps.Clone(smc.Tags)
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+
+	tags[logNameTag] = lc.Name
+	tags[shortNameTag] = lc.ShortName
+	tags[inceptionTag] = lc.Inception
+
+	return secretMetadata{
+		KmsKeyID:       smc.KmsKeyID,
+		Description:    smc.Description,
+		Tags:           tags,
+		ReplicaRegions: smc.ReplicaRegions,
+		Encoding:       smc.Encoding,
+	}
+}
+
+// checkReplicaRegions returns an error if a replica region is incomplete or
+// configured twice.
+func (smc secretManagerConfig) checkReplicaRegions() error {
+	seen := make(map[string]bool)
+	for _, replica := range smc.ReplicaRegions {
+		if replica.Region == "" {
+			return fmt.Errorf("replica region with kmskeyid %q has no region", replica.KmsKeyID)
+		}
+
+		if seen[replica.Region] {
+			return fmt.Errorf("replica region %q is configured twice", replica.Region)
+		}
+
+		seen[replica.Region] = true
+	}
+
+	return nil
+}
+
+// createSecretInput returns the request which creates the secret with the
+// given ID, holding seed with the metadata's encoding and described by the
+// metadata.
+func (meta secretMetadata) createSecretInput(id string, seed []byte, logID string) *secretsmanager.CreateSecretInput {
+	tags := make([]types.Tag, 0, len(meta.Tags)+1)
+	for _, key := range slices.Sorted(maps.Keys(meta.Tags)) {
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(meta.Tags[key])}) //nolint:exhaustruct
+	}
+
+	tags = append(tags, types.Tag{Key: aws.String(logIDTag), Value: aws.String(logID)}) //nolint:exhaustruct
+
+	var replicas []types.ReplicaRegionType
+	for _, replica := range meta.ReplicaRegions {
+		replicas = append(replicas, types.ReplicaRegionType{ //nolint:exhaustruct
+			Region:   aws.String(replica.Region),
+			KmsKeyId: optionalString(replica.KmsKeyID),
+		})
+	}
+
+	binary, str := encodeSeed(meta.Encoding, seed)
+
+	return &secretsmanager.CreateSecretInput{
+		Name:                        aws.String(id),
+		AddReplicaRegions:           replicas,
+		ClientRequestToken:          aws.String(seedRequestToken(id)),
+		Description:                 optionalString(meta.Description),
+		ForceOverwriteReplicaSecret: false,
+		KmsKeyId:                    optionalString(meta.KmsKeyID),
+		SecretBinary:                binary,
+		SecretString:                str,
+		Tags:                        tags,
+	}
+}
+
+// optionalString returns nil for the empty string, and a pointer to s
+// otherwise, since the AWS SDK distinguishes unset fields from empty ones.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
+}
+
+// waitForReplication polls the secret with the given ID until all of its
+// replicas are in sync. It fails if replication to any region fails, or if it
+// takes longer than timeout.
+func waitForReplication(ctx context.Context, smClient SecretsManager, id string, interval, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		res, err := smClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(id)})
+		if err != nil {
+			return fmt.Errorf("describing secret %q: %w", id, classifyError(err))
+		}
+
+		var pending []string
+		for _, replica := range res.ReplicationStatus {
+			switch replica.Status {
+			case types.StatusTypeInSync:
+			case types.StatusTypeFailed:
+				return fmt.Errorf("replicating secret %q to %s failed: %s",
+					id, aws.ToString(replica.Region), aws.ToString(replica.StatusMessage))
+			default:
+				pending = append(pending, aws.ToString(replica.Region))
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for secret %q to replicate to %v: %w", id, pending, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretMetadata(t *testing.T) {
+	t.Parallel()
+
+	smc := secretManagerConfig{ //nolint:exhaustruct
+		KmsKeyID:       "alias/sunlight",
+		Description:    "Sunlight log seed",
+		Tags:           map[string]string{"team": "ct"},
+		ReplicaRegions: []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: ""}},
+	}
+	lc := logConfig{Name: "test.tld/shard1", ShortName: "shard1", Inception: "2024-08-07"} //nolint:exhaustruct
+
+	got := smc.secretMetadata(lc)
+	want := secretMetadata{
+		KmsKeyID:    "alias/sunlight",
+		Description: "Sunlight log seed",
+		Tags: map[string]string{
+			"team":       "ct",
+			logNameTag:   "test.tld/shard1",
+			shortNameTag: "shard1",
+			inceptionTag: "2024-08-07",
+		},
+		ReplicaRegions: []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: ""}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("secretMetadata() = %#v, but want %#v", got, want)
+	}
+
+	if len(smc.Tags) != 1 {
+		t.Errorf("secretMetadata() modified the configured tags: %v", smc.Tags)
+	}
+}
+
+func TestWaitForReplication(t *testing.T) {
+	t.Parallel()
+
+	testSM := &fakeSecretsManager{} //nolint:exhaustruct
+	meta := secretMetadata{         //nolint:exhaustruct
+		ReplicaRegions: []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: ""}, {Region: "slow-west-1", KmsKeyID: ""}},
+	}
+
+	// Creating the secret itself would wait for minutes, so the fake is
+	// called directly.
+	_, err := testSM.CreateSecret(t.Context(), meta.createSecretInput("slow", newSeed(), "logid"))
+	if err != nil {
+		t.Fatalf("CreateSecret() = %q, but want success", err)
+	}
+
+	err = waitForReplication(t.Context(), testSM, "slow", time.Millisecond, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), `waiting for secret "slow" to replicate to [slow-west-1]: context deadline exceeded`) {
+		t.Errorf("waitForReplication() = %v, but want a timeout waiting for slow-west-1", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+const (
+	// currentStage is the staging label of the version of a secret which
+	// GetSecretValue returns by default.
+	currentStage = "AWSCURRENT"
+	// pinnedStage is the custom staging label with which we mark the version
+	// of a secret that holds the log's seed, the first time we use it. A log's
+	// seed must never change, so we refuse to use a secret whose current
+	// version is no longer the pinned one.
+	pinnedStage = "SUNLIGHT_PINNED"
+)
+
+// checkPinnedVersion makes sure that the seed in the secret with the given ID
+// has never changed. It fails if rotation is enabled on the secret, or if the
+// secret's current version is not both the fetched version, from which the seed
+// was read, and the one labelled with pinnedStage. If no version is labelled
+// yet, the current version must be the secret's first version, and it is
+// returned so that pinVersion can label it once the seed has passed every other
+// check. Otherwise, the empty string is returned. It never changes the secret.
+func checkPinnedVersion(ctx context.Context, smClient SecretsManager, id string, fetched string) (string, error) {
+	req := &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(id),
+	}
+
+	res, err := smClient.DescribeSecret(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("describing secret %q: %w", id, classifyError(err))
+	}
+
+	if aws.ToBool(res.RotationEnabled) {
+		return "", fmt.Errorf("secret %q has rotation enabled, but a log's seed must never change", id)
+	}
+
+	current := versionWithStage(res.VersionIdsToStages, currentStage)
+	if current == "" {
+		return "", fmt.Errorf("secret %q has no %s version", id, currentStage)
+	}
+
+	// The current version may have moved since the seed was fetched, in which
+	// case checking it would say nothing about the seed we have.
+	if current != fetched {
+		return "", fmt.Errorf("secret %q has %s version %s, but the seed was read from version %q", id, currentStage, current, fetched)
+	}
+
+	pinned := versionWithStage(res.VersionIdsToStages, pinnedStage)
+	if pinned != "" {
+		if pinned != current {
+			return "", fmt.Errorf("secret %q has %s version %s, but its %s version is %s: the seed has changed since it was first used",
+				id, currentStage, current, pinnedStage, pinned)
+		}
+
+		return "", nil
+	}
+
+	first, err := firstVersion(ctx, smClient, id)
+	if err != nil {
+		return "", err
+	}
+
+	if first != current {
+		return "", fmt.Errorf("secret %q has %s version %s, but its first version is %s: the seed has changed since it was created",
+			id, currentStage, current, first)
+	}
+
+	return current, nil
+}
+
+// pinVersion labels the version of the secret with the given ID with
+// pinnedStage.
+func pinVersion(ctx context.Context, smClient SecretsManager, id string, version string) error {
+	stageReq := &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(id),
+		VersionStage:        aws.String(pinnedStage),
+		MoveToVersionId:     aws.String(version),
+		RemoveFromVersionId: nil,
+	}
+
+	_, err := smClient.UpdateSecretVersionStage(ctx, stageReq)
+	if err != nil {
+		return fmt.Errorf("pinning version %s of secret %q: %w", version, id, classifyError(err))
+	}
+
+	log.Printf("Pinned version %s of secret %q with staging label %s", version, id, pinnedStage)
+
+	return nil
+}
+
+// versionWithStage returns the ID of the version which has the given staging
+// label, or the empty string if there is none.
+func versionWithStage(versionIDsToStages map[string][]string, stage string) string {
+	for versionID, stages := range versionIDsToStages {
+		if slices.Contains(stages, stage) {
+			return versionID
+		}
+	}
+
+	return ""
+}
+
+// firstVersion returns the ID of the oldest version of the secret with the
+// given ID, including deprecated versions which no longer have any staging
+// labels.
+func firstVersion(ctx context.Context, smClient SecretsManager, id string) (string, error) {
+	req := &secretsmanager.ListSecretVersionIdsInput{
+		SecretId:          aws.String(id),
+		IncludeDeprecated: aws.Bool(true),
+		MaxResults:        nil,
+		NextToken:         nil,
+	}
+
+	var first string
+	var firstCreated int64
+
+	paginator := secretsmanager.NewListSecretVersionIdsPaginator(smClient, req)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("listing versions of secret %q: %w", id, classifyError(err))
+		}
+
+		for _, version := range page.Versions {
+			created := aws.ToTime(version.CreatedDate).UnixNano()
+			if first == "" || created < firstCreated {
+				first, firstCreated = aws.ToString(version.VersionId), created
+			}
+		}
+	}
+
+	if first == "" {
+		return "", fmt.Errorf("secret %q has no versions", id)
+	}
+
+	return first, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPinnedVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name         string
+		id           string
+		fetched      string
+		wantUnpinned string
+		wantErr      string
+	}{
+		{
+			name:         "unpinned first version",
+			id:           "real",
+			fetched:      "v1",
+			wantUnpinned: "v1",
+			wantErr:      "",
+		},
+		{
+			name:         "pinned current version",
+			id:           "pinned",
+			fetched:      "v1",
+			wantUnpinned: "",
+			wantErr:      "",
+		},
+		{
+			name:         "current version is not the fetched one",
+			id:           "pinned",
+			fetched:      "v0",
+			wantUnpinned: "",
+			wantErr:      `secret "pinned" has AWSCURRENT version v1, but the seed was read from version "v0"`,
+		},
+		{
+			name:         "rotation enabled",
+			id:           "rotating",
+			fetched:      "v1",
+			wantUnpinned: "",
+			wantErr:      `secret "rotating" has rotation enabled`,
+		},
+		{
+			name:         "current version is not pinned",
+			id:           "changed",
+			fetched:      "v2",
+			wantUnpinned: "",
+			wantErr:      "has AWSCURRENT version v2, but its SUNLIGHT_PINNED version is v1: the seed has changed since it was first used",
+		},
+		{
+			name:         "current version is not the first",
+			id:           "replaced",
+			fetched:      "v2",
+			wantUnpinned: "",
+			wantErr:      "has AWSCURRENT version v2, but its first version is v1: the seed has changed since it was created",
+		},
+		{
+			name:         "no current version",
+			id:           "stale",
+			fetched:      "v1",
+			wantUnpinned: "",
+			wantErr:      `secret "stale" has no AWSCURRENT version`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSM := &fakeSecretsManager{} //nolint:exhaustruct
+
+			unpinned, err := checkPinnedVersion(t.Context(), testSM, tc.id, tc.fetched)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("checkPinnedVersion(%q) = %q, but want error %q", tc.id, unpinned, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("checkPinnedVersion(%q) = %q, but want error %q", tc.id, err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("checkPinnedVersion(%q) = %q, but want success", tc.id, err)
+			}
+
+			if unpinned != tc.wantUnpinned {
+				t.Errorf("checkPinnedVersion(%q) = %q, but want %q", tc.id, unpinned, tc.wantUnpinned)
+			}
+
+			if got := testSM.pinned[tc.id]; got != "" {
+				t.Errorf("checkPinnedVersion(%q) pinned version %q, but want nothing pinned", tc.id, got)
+			}
+
+			if unpinned == "" {
+				return
+			}
+
+			err = pinVersion(t.Context(), testSM, tc.id, unpinned)
+			if err != nil {
+				t.Fatalf("pinVersion(%q) = %q, but want success", tc.id, err)
+			}
+
+			if got := testSM.pinned[tc.id]; got != unpinned {
+				t.Errorf("pinVersion(%q) pinned version %q, but want %q", tc.id, got, unpinned)
+			}
+
+			// Once pinned, the version should keep passing the check.
+			unpinned, err = checkPinnedVersion(t.Context(), testSM, tc.id, tc.fetched)
+			if err != nil || unpinned != "" {
+				t.Errorf("checkPinnedVersion(%q) = %q, %v on second call, but want success", tc.id, unpinned, err)
+			}
+		})
+	}
+}
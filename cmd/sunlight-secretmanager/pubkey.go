@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// pubkeyCommand prints the public key of a single log, derived from the seed
+// stored in Secrets Manager. It checks the seed as writeCommand does, but never
+// creates or pins a seed and never writes anything to disk.
+func pubkeyCommand(args []string) {
+	flagset := flag.NewFlagSet("sunlight-secretmanager pubkey", flag.ContinueOnError)
+	configFlag := flagset.String("config", "", "Path to YAML config file")
+	logFlag := flagset.String("log", "", "Name of the log whose public key to print")
+	formatFlag := flagset.String("format", "pem", "Output format: pem, der (base64 SPKI), jwk, or logid")
+
+	err := flagset.Parse(args)
+	if err != nil {
+		log.Fatalf("Error parsing flags: %s", err)
+	}
+
+	config, err := loadConfig(*configFlag)
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+
+	logConf, err := config.findLog(*logFlag)
+	if err != nil {
+		log.Fatalf("Error finding log: %s", err)
+	}
+
+	ctx := context.Background()
+
+	backend, err := newBackend(ctx, config.SecretManager)
+	if err != nil {
+		log.Fatalf("Error creating seed backend: %s", err)
+	}
+
+	store, err := backend.store(logConf)
+	if err != nil {
+		log.Fatalf("Error fetching seed for log %q: %v", logConf.Name, err)
+	}
+
+	_, keys, err := fetchCheckedSeed(ctx, config, logConf, store)
+	if err != nil {
+		log.Fatalf("Error checking seed for log %q: %v", logConf.Name, err)
+	}
+
+	out, err := formatPublicKey(keys, *formatFlag)
+	if err != nil {
+		log.Fatalf("Error formatting public key for log %q: %v", logConf.Name, err)
+	}
+
+	_, _ = os.Stdout.Write(out)
+}
+
+// fetchCheckedSeed fetches the log's seed from its store, without ever creating
+// one, and returns it along with its keys. Before doing so, it checks the seed
+// as writeCommand does: its length and content, its log ID, its version against
+// the pinned one, and the safety of the secret. It never pins the version.
+func fetchCheckedSeed(ctx context.Context, conf *config, logConf logConfig, store seedStore) ([]byte, *logKeys, error) {
+	seed, version, err := store.Get(ctx, logConf.SecretID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching seed: %w", err)
+	}
+
+	err = conf.SecretManager.checkSeed(seed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid seed: %w", err)
+	}
+
+	keys, err := deriveKeys(seed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error deriving keys: %w", err)
+	}
+
+	err = keys.checkLogID(logConf.LogID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = retryTransient(ctx, transientRetryDelays, func() (struct{}, error) {
+		return struct{}{}, store.CheckPin(ctx, logConf.SecretID, version)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error checking seed version: %w", err)
+	}
+
+	_, err = retryTransient(ctx, transientRetryDelays, func() (struct{}, error) {
+		return struct{}{}, store.CheckSafety(ctx, logConf.SecretID, version)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error checking secret: %w", err)
+	}
+
+	return seed, keys, nil
+}
+
+// formatPublicKey encodes the log's public key in the given format, with a
+// trailing newline.
+func formatPublicKey(keys *logKeys, format string) ([]byte, error) {
+	switch format {
+	case "pem":
+		return keys.publicKeyPEM(), nil
+	case "der":
+		return []byte(keys.spkiBase64() + "\n"), nil
+	case "jwk":
+		jwk, err := keys.publicKeyJWK()
+		if err != nil {
+			return nil, err
+		}
+
+		return append(jwk, '\n'), nil
+	case "logid":
+		return []byte(keys.logIDBase64() + "\n"), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q: must be one of pem, der, jwk, logid", format)
+	}
+}
+
+// p256CoordLen is the length in bytes of each coordinate of a P-256 point.
+const p256CoordLen = 32
+
+// jwk is an RFC 7517 JSON Web Key for an elliptic curve public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKeyJWK returns the log's public key as an RFC 7517 JSON Web Key.
+func (k *logKeys) publicKeyJWK() ([]byte, error) {
+	pub, err := k.PublicKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("converting public key: %w", err)
+	}
+
+	// The uncompressed point encoding is 0x04 || X || Y.
+	point := pub.Bytes()
+	if len(point) != 1+2*p256CoordLen || point[0] != 0x04 {
+		return nil, errors.New("unexpected public key encoding")
+	}
+
+	out, err := json.Marshal(jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1 : 1+p256CoordLen]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[1+p256CoordLen:]),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding JWK: %w", err)
+	}
+
+	return out, nil
+}
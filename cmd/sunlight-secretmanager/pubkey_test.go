@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatPublicKey(t *testing.T) {
+	t.Parallel()
+
+	keys, err := deriveKeys(make([]byte, seedLen))
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	for _, tc := range []struct {
+		format  string
+		want    string
+		wantErr string
+	}{
+		{
+			format: "pem",
+			want: "-----BEGIN PUBLIC KEY-----\n" +
+				"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEHm9VJxsjVA4jUWTJirhJFLJnpHLl\n" +
+				"24Mib2kqf38/A76cCUdoYipPZlhEl7WKde+4w9vG13ecEcRN3dCaiyfR0w==\n" +
+				"-----END PUBLIC KEY-----\n",
+			wantErr: "",
+		},
+		{
+			format:  "der",
+			want:    "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEHm9VJxsjVA4jUWTJirhJFLJnpHLl24Mib2kqf38/A76cCUdoYipPZlhEl7WKde+4w9vG13ecEcRN3dCaiyfR0w==\n",
+			wantErr: "",
+		},
+		{
+			format:  "jwk",
+			want:    `{"kty":"EC","crv":"P-256","x":"Hm9VJxsjVA4jUWTJirhJFLJnpHLl24Mib2kqf38_A74","y":"nAlHaGIqT2ZYRJe1inXvuMPbxtd3nBHETd3Qmosn0dM"}` + "\n",
+			wantErr: "",
+		},
+		{
+			format:  "logid",
+			want:    "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0=\n",
+			wantErr: "",
+		},
+		{
+			format:  "xml",
+			want:    "",
+			wantErr: "unknown format",
+		},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := formatPublicKey(keys, tc.format)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("formatPublicKey(%q) = %q, but want error %q", tc.format, got, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("formatPublicKey(%q) = %#v, but want error %q", tc.format, err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("formatPublicKey(%q) = %#v, but want no error", tc.format, err)
+				} else if string(got) != tc.want {
+					t.Errorf("formatPublicKey(%q) = %q, but want %q", tc.format, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchCheckedSeed(t *testing.T) {
+	t.Parallel()
+
+	seed := make([]byte, seedLen)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	keys, err := deriveKeys(seed)
+	if err != nil {
+		t.Fatalf("deriveKeys() = %#v, but want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		id      string
+		logID   string
+		pinned  string
+		wantErr string
+	}{
+		{
+			name:    "happy path",
+			id:      "good",
+			logID:   keys.logIDBase64(),
+			pinned:  "1",
+			wantErr: "",
+		},
+		{
+			name:    "not pinned yet",
+			id:      "good",
+			logID:   "",
+			pinned:  "",
+			wantErr: "",
+		},
+		{
+			name:    "absent secret",
+			id:      "absent",
+			logID:   "",
+			pinned:  "",
+			wantErr: "error fetching seed",
+		},
+		{
+			name:    "bad seed",
+			id:      "zeros",
+			logID:   "",
+			pinned:  "",
+			wantErr: "invalid seed",
+		},
+		{
+			name:    "wrong log ID",
+			id:      "good",
+			logID:   "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0=",
+			pinned:  "",
+			wantErr: "does not match expected log ID",
+		},
+		{
+			name:    "other version pinned",
+			id:      "good",
+			logID:   "",
+			pinned:  "2",
+			wantErr: `error checking seed version: secret "good" has version 1, but version 2 is pinned`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &memoryStore{ //nolint:exhaustruct
+				seeds:  map[string][]byte{"good": seed, "zeros": make([]byte, seedLen)},
+				pinned: map[string]string{},
+			}
+			if tc.pinned != "" {
+				store.pinned[tc.id] = tc.pinned
+			}
+
+			logConf := logConfig{Name: "test.tld/shard1", SecretID: tc.id, LogID: tc.logID} //nolint:exhaustruct
+			conf := &config{Logs: []logConfig{logConf}}                                     //nolint:exhaustruct
+
+			gotSeed, got, err := fetchCheckedSeed(t.Context(), conf, logConf, store)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("fetchCheckedSeed(%q) = %v, but want error %q", tc.id, err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("fetchCheckedSeed(%q) = %q, but want success", tc.id, err)
+			}
+
+			if !bytes.Equal(gotSeed, seed) {
+				t.Errorf("fetchCheckedSeed(%q) = %x, but want %x", tc.id, gotSeed, seed)
+			}
+
+			if got.logIDBase64() != keys.logIDBase64() {
+				t.Errorf("fetchCheckedSeed(%q) has log ID %s, but want %s", tc.id, got.logIDBase64(), keys.logIDBase64())
+			}
+
+			if store.pinned[tc.id] != tc.pinned {
+				t.Errorf("fetchCheckedSeed(%q) pinned version %s, but want nothing pinned", tc.id, store.pinned[tc.id])
+			}
+		})
+	}
+}
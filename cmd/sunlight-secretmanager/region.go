@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// regionConfig is a region from which seeds can be read.
+type regionConfig struct {
+	// Region is the AWS region, such as "us-west-2".
+	Region string
+	// Endpoint is the URL of the Secrets Manager endpoint to use in Region,
+	// such as a VPC endpoint. It is optional, and defaults to the region's
+	// public endpoint.
+	Endpoint string
+}
+
+// regionalClient is a Secrets Manager client for a single region.
+type regionalClient struct {
+	Region string
+	Client SecretsManager
+}
+
+// checkRegions returns an error if a region is incomplete or configured twice,
+// or if its endpoint is not an absolute URL.
+func (smc secretManagerConfig) checkRegions() error {
+	seen := make(map[string]bool)
+	for _, region := range smc.Regions {
+		if region.Region == "" {
+			return fmt.Errorf("region with endpoint %q has no region", region.Endpoint)
+		}
+
+		if seen[region.Region] {
+			return fmt.Errorf("region %q is configured twice", region.Region)
+		}
+
+		seen[region.Region] = true
+
+		if region.Endpoint != "" {
+			u, err := url.Parse(region.Endpoint)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("endpoint %q of region %q is not an absolute URL", region.Endpoint, region.Region)
+			}
+		}
+	}
+
+	return nil
+}
+
+// regionalClients returns a Secrets Manager client for each of the given
+// regions, in order, configured by cfg. If no regions are given, it returns a
+// single client for the region configured by cfg.
+func regionalClients(cfg aws.Config, regions []regionConfig) []regionalClient {
+	if len(regions) == 0 {
+		return []regionalClient{{Region: cfg.Region, Client: secretsmanager.NewFromConfig(cfg)}}
+	}
+
+	clients := make([]regionalClient, 0, len(regions))
+	for _, region := range regions {
+		client := secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+			o.Region = region.Region
+			if region.Endpoint != "" {
+				o.BaseEndpoint = aws.String(region.Endpoint)
+			}
+		})
+
+		clients = append(clients, regionalClient{Region: region.Region, Client: client})
+	}
+
+	return clients
+}
+
+// fetchSeedFailover fetches the seed with the given ID like fetchSeed, from the
+// first of clients, which is the primary region. If that fails with
+// errTransient, it tries each of the other regions in turn, and returns the
+// version of the seed in the one which served it. Other errors, including
+// errSecretNotFound, are returned straight away. If every region fails with
+// errTransient, so does fetchSeedFailover.
+func fetchSeedFailover(ctx context.Context, clients []regionalClient, id string, encoding string) ([]byte, seedVersion, error) {
+	var errs []error
+	for i, client := range clients {
+		seed, versionID, err := fetchSeed(ctx, client.Client, id, encoding)
+		if !errors.Is(err, errTransient) {
+			return seed, regionVersion(clients, i, versionID), err
+		}
+
+		errs = append(errs, fmt.Errorf("region %s: %w", client.Region, err))
+
+		if i+1 < len(clients) {
+			log.Printf("Fetching secret %q from region %s failed, trying region %s: %v", id, client.Region, clients[i+1].Region, err)
+		}
+	}
+
+	return nil, regionVersion(clients, 0, ""), fmt.Errorf("fetching secret %q failed in every region: %w", id, errors.Join(errs...))
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// downSecretsManager simulates a region whose Secrets Manager endpoint is down.
+type downSecretsManager struct {
+	SecretsManager
+}
+
+func (downSecretsManager) GetSecretValue(context.Context, *secretsmanager.GetSecretValueInput, ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	return nil, &types.InternalServiceError{Message: aws.String("An error occurred on the server side.")} //nolint:exhaustruct
+}
+
+func (downSecretsManager) BatchGetSecretValue(context.Context, *secretsmanager.BatchGetSecretValueInput, ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	return nil, &types.InternalServiceError{Message: aws.String("An error occurred on the server side.")} //nolint:exhaustruct
+}
+
+func TestCheckRegions(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		regions []regionConfig
+		wantErr string
+	}{
+		{
+			name:    "none",
+			regions: nil,
+			wantErr: "",
+		},
+		{
+			name:    "valid",
+			regions: []regionConfig{{Region: "us-west-2", Endpoint: ""}, {Region: "us-east-2", Endpoint: "https://vpce-1234.secretsmanager.us-east-2.vpce.amazonaws.com"}},
+			wantErr: "",
+		},
+		{
+			name:    "no region",
+			regions: []regionConfig{{Region: "", Endpoint: "https://secretsmanager.example"}},
+			wantErr: `region with endpoint "https://secretsmanager.example" has no region`,
+		},
+		{
+			name:    "duplicate region",
+			regions: []regionConfig{{Region: "us-west-2", Endpoint: ""}, {Region: "us-west-2", Endpoint: ""}},
+			wantErr: `region "us-west-2" is configured twice`,
+		},
+		{
+			name:    "relative endpoint",
+			regions: []regionConfig{{Region: "us-west-2", Endpoint: "secretsmanager.us-west-2.amazonaws.com"}},
+			wantErr: `endpoint "secretsmanager.us-west-2.amazonaws.com" of region "us-west-2" is not an absolute URL`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := secretManagerConfig{Regions: tc.regions}.checkRegions() //nolint:exhaustruct
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("checkRegions() = %q, but want success", err)
+			} else if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Errorf("checkRegions() = %v, but want error %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchSeedFailover(t *testing.T) {
+	t.Parallel()
+
+	up := &fakeSecretsManager{} //nolint:exhaustruct
+	down := downSecretsManager{SecretsManager: nil}
+
+	for _, tc := range []struct {
+		name       string
+		id         string
+		clients    []regionalClient
+		wantSeed   []byte
+		wantServed int
+		wantErr    error
+	}{
+		{
+			name:       "primary",
+			id:         "real",
+			clients:    []regionalClient{{Region: "us-west-2", Client: up}, {Region: "us-east-2", Client: up}},
+			wantSeed:   []byte("hello world"),
+			wantServed: 0,
+			wantErr:    nil,
+		},
+		{
+			name:       "primary down",
+			id:         "real",
+			clients:    []regionalClient{{Region: "us-west-2", Client: down}, {Region: "us-east-2", Client: up}},
+			wantSeed:   []byte("hello world"),
+			wantServed: 1,
+			wantErr:    nil,
+		},
+		{
+			name:       "primary and first replica down",
+			id:         "real",
+			clients:    []regionalClient{{Region: "us-west-2", Client: down}, {Region: "us-east-2", Client: down}, {Region: "eu-west-1", Client: up}},
+			wantSeed:   []byte("hello world"),
+			wantServed: 2,
+			wantErr:    nil,
+		},
+		{
+			name:       "not found in primary",
+			id:         "absent",
+			clients:    []regionalClient{{Region: "us-west-2", Client: up}, {Region: "us-east-2", Client: down}},
+			wantSeed:   nil,
+			wantServed: 0,
+			wantErr:    errSecretNotFound,
+		},
+		{
+			name:       "every region down",
+			id:         "real",
+			clients:    []regionalClient{{Region: "us-west-2", Client: down}, {Region: "us-east-2", Client: down}},
+			wantSeed:   nil,
+			wantServed: 0,
+			wantErr:    errTransient,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			seed, version, err := fetchSeedFailover(t.Context(), tc.clients, tc.id, "")
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("fetchSeedFailover(%q) = %v, but want error wrapping %q", tc.id, err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("fetchSeedFailover(%q) = %q, but want success", tc.id, err)
+			}
+
+			want := regionVersion(tc.clients, tc.wantServed, "v1")
+			if !bytes.Equal(seed, tc.wantSeed) || version != want {
+				t.Errorf("fetchSeedFailover(%q) = %q from version %+v, but want %q from version %+v", tc.id, seed, version, tc.wantSeed, want)
+			}
+		})
+	}
+}
+
+func TestGetOrCreateSeedFailover(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.June, 15, 23, 59, 59, 0, time.UTC)
+	today := now.Format(time.DateOnly)
+
+	for _, tc := range []struct {
+		name       string
+		id         string
+		wantSeed   []byte
+		wantRegion string
+		wantErr    string
+	}{
+		{
+			name:       "existing seed",
+			id:         "real",
+			wantSeed:   []byte("hello world"),
+			wantRegion: "us-east-2",
+			wantErr:    "",
+		},
+		{
+			name:       "changed seed",
+			id:         "changed",
+			wantSeed:   nil,
+			wantRegion: "",
+			wantErr:    "the seed has changed since it was first used",
+		},
+		{
+			name:       "empty seed",
+			id:         "terraform",
+			wantSeed:   nil,
+			wantRegion: "",
+			wantErr:    "log has empty seed in replica us-east-2, but seeds can only be created in the primary",
+		},
+		{
+			name:       "absent secret",
+			id:         "absent",
+			wantSeed:   nil,
+			wantRegion: "",
+			wantErr:    "log has empty seed in replica us-east-2, but seeds can only be created in the primary",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			replica := &fakeSecretsManager{} //nolint:exhaustruct
+			clients := []regionalClient{
+				{Region: "us-west-2", Client: downSecretsManager{SecretsManager: nil}},
+				{Region: "us-east-2", Client: replica},
+			}
+			logConf := logConfig{Name: "test.tld/shard1", Inception: today} //nolint:exhaustruct
+			conf := &config{Logs: []logConfig{logConf}}                     //nolint:exhaustruct
+
+			fetched := fetchSeeds(t.Context(), clients, []string{tc.id}, "")
+
+			got, served, err := getOrCreateSeed(t.Context(), tc.id, logConf, conf, newAWSStore(roleClients{Regions: clients, STS: nil}, conf.SecretManager, logConf, ""), fetched[tc.id], now)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("getOrCreateSeed(%q) = %v, but want error %q", tc.id, err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("getOrCreateSeed(%q) = %q, but want success", tc.id, err)
+			}
+
+			if !bytes.Equal(got, tc.wantSeed) || served.Source != tc.wantRegion {
+				t.Errorf("getOrCreateSeed(%q) = %q from region %s, but want %q from region %s", tc.id, got, served.Source, tc.wantSeed, tc.wantRegion)
+			}
+
+			if len(replica.pinned) != 0 || len(replica.stored) != 0 {
+				t.Errorf("getOrCreateSeed(%q) wrote to the replica region", tc.id)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// roleConfig is the IAM role with which a log's secret is accessed, and the
+// AWS account to which the secret belongs.
+type roleConfig struct {
+	// RoleARN is the ARN of the IAM role to assume, such as
+	// "arn:aws:iam::123456789012:role/sunlight". It is optional, and defaults
+	// to using the credentials from the environment as they are.
+	RoleARN string
+	// ExternalID is the external ID with which to assume RoleARN. It is
+	// optional.
+	ExternalID string
+	// AccountID is the ID of the AWS account to which the secret belongs. It
+	// is optional. If it is set, seeds are only created if the credentials
+	// used belong to that account.
+	AccountID string
+}
+
+var (
+	// roleARNPattern matches the ARNs of IAM roles, capturing their account.
+	roleARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(\d{12}):role/[\w+=,.@/-]+$`)
+	// accountIDPattern matches AWS account IDs.
+	accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+)
+
+// role returns the role with which the secret of the given log is accessed.
+// A log's RoleARN, along with its ExternalID, overrides the role configured
+// for its Env, and so does its AccountID. If any Environments are configured,
+// a log's Env must be one of them unless the log has a RoleARN of its own, so
+// that a typo doesn't silently fall back to the credentials from the
+// environment.
+func (smc secretManagerConfig) role(lc logConfig) (roleConfig, error) {
+	role, ok := smc.Environments[lc.Env]
+	if !ok && lc.Env != "" && lc.RoleARN == "" && len(smc.Environments) > 0 {
+		return roleConfig{}, fmt.Errorf("env %q is not one of the configured environments %q", //nolint:exhaustruct
+			lc.Env, slices.Sorted(maps.Keys(smc.Environments)))
+	}
+
+	if lc.RoleARN != "" {
+		role.RoleARN, role.ExternalID = lc.RoleARN, lc.ExternalID
+	}
+
+	if lc.AccountID != "" {
+		role.AccountID = lc.AccountID
+	}
+
+	return role, nil
+}
+
+// checkRole returns an error if the role with which the secret of the given log
+// is accessed is invalid.
+func (smc secretManagerConfig) checkRole(lc logConfig) error {
+	if lc.ExternalID != "" && lc.RoleARN == "" {
+		return fmt.Errorf("externalid %q is only used with rolearn", lc.ExternalID)
+	}
+
+	role, err := smc.role(lc)
+	if err != nil {
+		return err
+	}
+
+	return role.check()
+}
+
+// checkEnvironments returns an error if the role of any environment is invalid.
+func (smc secretManagerConfig) checkEnvironments() error {
+	for _, env := range slices.Sorted(maps.Keys(smc.Environments)) {
+		err := smc.Environments[env].check()
+		if err != nil {
+			return fmt.Errorf("environment %q: %w", env, err)
+		}
+	}
+
+	return nil
+}
+
+// check returns an error if RoleARN or AccountID is malformed, if they name
+// different accounts, or if ExternalID is set without RoleARN.
+func (rc roleConfig) check() error {
+	if rc.ExternalID != "" && rc.RoleARN == "" {
+		return fmt.Errorf("externalid %q is only used with rolearn", rc.ExternalID)
+	}
+
+	if rc.AccountID != "" && !accountIDPattern.MatchString(rc.AccountID) {
+		return fmt.Errorf("accountid %q is not a 12-digit AWS account ID", rc.AccountID)
+	}
+
+	if rc.RoleARN == "" {
+		return nil
+	}
+
+	match := roleARNPattern.FindStringSubmatch(rc.RoleARN)
+	if match == nil {
+		return fmt.Errorf("rolearn %q is not the ARN of an IAM role", rc.RoleARN)
+	}
+
+	if rc.AccountID != "" && match[1] != rc.AccountID {
+		return fmt.Errorf("rolearn %q is in account %s, but accountid is %s", rc.RoleARN, match[1], rc.AccountID)
+	}
+
+	return nil
+}
+
+// STS defines the subset of methods we need from AWS STS, so that it can be
+// mocked for testing.
+type STS interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// roleClients are the clients with which the secrets of the logs sharing a role
+// are accessed.
+type roleClients struct {
+	// Regions are the Secrets Manager clients for each region, in order.
+	Regions []regionalClient
+	// STS is the STS client, which tells which account the role belongs to.
+	STS STS
+}
+
+// clientCache builds the clients for each role the first time they are needed,
+// and reuses them from then on, so that each role is only assumed once.
+type clientCache struct {
+	cfg     aws.Config
+	regions []regionConfig
+
+	mu      sync.Mutex
+	clients map[roleConfig]roleClients
+}
+
+// newClientCache returns a clientCache whose clients use the given regions and
+// are configured from the environment, using the shared config profile named
+// by AWS_PROFILE.
+func newClientCache(ctx context.Context, regions []regionConfig) (*clientCache, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(os.Getenv("AWS_PROFILE")))
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	return &clientCache{
+		cfg:     cfg,
+		regions: regions,
+		mu:      sync.Mutex{},
+		clients: make(map[roleConfig]roleClients),
+	}, nil
+}
+
+// forRole returns the clients for the given role. If the role has a RoleARN,
+// their credentials come from assuming it with STS AssumeRole, using the
+// credentials from the environment.
+func (cc *clientCache) forRole(role roleConfig) roleClients {
+	// The account doesn't change the credentials.
+	key := roleConfig{RoleARN: role.RoleARN, ExternalID: role.ExternalID, AccountID: ""}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if clients, ok := cc.clients[key]; ok {
+		return clients
+	}
+
+	cfg := cc.cfg.Copy()
+	if role.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cc.cfg), role.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.ExternalID = optionalString(role.ExternalID)
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	clients := roleClients{
+		Regions: regionalClients(cfg, cc.regions),
+		STS:     sts.NewFromConfig(cfg),
+	}
+	cc.clients[key] = clients
+
+	return clients
+}
+
+// checkAccount returns an error unless GetCallerIdentity reports that our
+// credentials belong to the given account.
+func checkAccount(ctx context.Context, stsClient STS, account string) error {
+	res, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("getting caller identity: %w", err)
+	}
+
+	if aws.ToString(res.Account) != account {
+		return fmt.Errorf("caller %s belongs to account %s, but the log's secret belongs to account %s",
+			aws.ToString(res.Arn), aws.ToString(res.Account), account)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// fakeSTS reports that the caller belongs to account.
+type fakeSTS struct {
+	account string
+}
+
+func (f fakeSTS) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{ //nolint:exhaustruct
+		Account: aws.String(f.account),
+		Arn:     aws.String("arn:aws:sts::" + f.account + ":assumed-role/sunlight/host1"),
+	}, nil
+}
+
+func TestRole(t *testing.T) {
+	t.Parallel()
+
+	smc := secretManagerConfig{ //nolint:exhaustruct
+		Environments: map[string]roleConfig{
+			"prod": {RoleARN: "arn:aws:iam::111111111111:role/sunlight", ExternalID: "prod", AccountID: "111111111111"},
+			"dev":  {RoleARN: "", ExternalID: "", AccountID: ""},
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		smc     secretManagerConfig
+		lc      logConfig
+		want    roleConfig
+		wantErr string
+	}{
+		{
+			name:    "no environments",
+			smc:     secretManagerConfig{},     //nolint:exhaustruct
+			lc:      logConfig{Env: "staging"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "", ExternalID: "", AccountID: ""},
+			wantErr: "",
+		},
+		{
+			name:    "unknown environment",
+			smc:     smc,
+			lc:      logConfig{Env: "staging"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "", ExternalID: "", AccountID: ""},
+			wantErr: `env "staging" is not one of the configured environments ["dev" "prod"]`,
+		},
+		{
+			name:    "no environment",
+			smc:     smc,
+			lc:      logConfig{Env: ""}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "", ExternalID: "", AccountID: ""},
+			wantErr: "",
+		},
+		{
+			name:    "unknown environment with log role",
+			smc:     smc,
+			lc:      logConfig{Env: "staging", RoleARN: "arn:aws:iam::222222222222:role/sunlight"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "arn:aws:iam::222222222222:role/sunlight", ExternalID: "", AccountID: ""},
+			wantErr: "",
+		},
+		{
+			name:    "environment without role",
+			smc:     smc,
+			lc:      logConfig{Env: "dev"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "", ExternalID: "", AccountID: ""},
+			wantErr: "",
+		},
+		{
+			name:    "environment role",
+			smc:     smc,
+			lc:      logConfig{Env: "prod"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "arn:aws:iam::111111111111:role/sunlight", ExternalID: "prod", AccountID: "111111111111"},
+			wantErr: "",
+		},
+		{
+			name:    "log role",
+			smc:     smc,
+			lc:      logConfig{Env: "prod", RoleARN: "arn:aws:iam::222222222222:role/sunlight", AccountID: "222222222222"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "arn:aws:iam::222222222222:role/sunlight", ExternalID: "", AccountID: "222222222222"},
+			wantErr: "",
+		},
+		{
+			name:    "log account",
+			smc:     smc,
+			lc:      logConfig{Env: "dev", AccountID: "333333333333"}, //nolint:exhaustruct
+			want:    roleConfig{RoleARN: "", ExternalID: "", AccountID: "333333333333"},
+			wantErr: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.smc.role(tc.lc)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("role() = %+v, %v, but want error %q", got, err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("role() = %q, but want %+v", err, tc.want)
+			} else if got != tc.want {
+				t.Errorf("role() = %+v, but want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckRole(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		role    roleConfig
+		wantErr string
+	}{
+		{
+			name:    "none",
+			role:    roleConfig{RoleARN: "", ExternalID: "", AccountID: ""},
+			wantErr: "",
+		},
+		{
+			name:    "complete",
+			role:    roleConfig{RoleARN: "arn:aws:iam::111111111111:role/ct/sunlight", ExternalID: "prod", AccountID: "111111111111"},
+			wantErr: "",
+		},
+		{
+			name:    "external ID without role",
+			role:    roleConfig{RoleARN: "", ExternalID: "prod", AccountID: ""},
+			wantErr: `externalid "prod" is only used with rolearn`,
+		},
+		{
+			name:    "bad role ARN",
+			role:    roleConfig{RoleARN: "arn:aws:iam::111111111111:user/sunlight", ExternalID: "", AccountID: ""},
+			wantErr: `rolearn "arn:aws:iam::111111111111:user/sunlight" is not the ARN of an IAM role`,
+		},
+		{
+			name:    "bad account ID",
+			role:    roleConfig{RoleARN: "", ExternalID: "", AccountID: "1111"},
+			wantErr: `accountid "1111" is not a 12-digit AWS account ID`,
+		},
+		{
+			name:    "role in other account",
+			role:    roleConfig{RoleARN: "arn:aws:iam::111111111111:role/sunlight", ExternalID: "", AccountID: "222222222222"},
+			wantErr: `rolearn "arn:aws:iam::111111111111:role/sunlight" is in account 111111111111, but accountid is 222222222222`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.role.check()
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("check() = %q, but want success", err)
+			} else if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Errorf("check() = %v, but want error %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	t.Parallel()
+
+	cc := &clientCache{ //nolint:exhaustruct
+		cfg:     aws.Config{Region: "us-west-2"}, //nolint:exhaustruct
+		regions: []regionConfig{{Region: "us-west-2", Endpoint: ""}, {Region: "us-east-2", Endpoint: ""}},
+		clients: make(map[roleConfig]roleClients),
+	}
+
+	prod := roleConfig{RoleARN: "arn:aws:iam::111111111111:role/sunlight", ExternalID: "", AccountID: "111111111111"}
+	staging := roleConfig{RoleARN: "arn:aws:iam::222222222222:role/sunlight", ExternalID: "", AccountID: ""}
+
+	first := cc.forRole(prod)
+	if len(first.Regions) != 2 || first.Regions[0].Region != "us-west-2" || first.Regions[1].Region != "us-east-2" {
+		t.Errorf("forRole() = %+v, but want clients for us-west-2 and us-east-2", first.Regions)
+	}
+
+	prod.AccountID = ""
+	if again := cc.forRole(prod); again.Regions[0].Client != first.Regions[0].Client {
+		t.Error("forRole() built new clients for a role it has already assumed")
+	}
+
+	if other := cc.forRole(staging); other.Regions[0].Client == first.Regions[0].Client {
+		t.Error("forRole() reused the clients of another role")
+	}
+}
+
+func TestGetOrCreateSeedAccount(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.June, 15, 23, 59, 59, 0, time.UTC)
+	today := now.Format(time.DateOnly)
+
+	for _, tc := range []struct {
+		name    string
+		account string
+		wantErr string
+	}{
+		{
+			name:    "right account",
+			account: "111111111111",
+			wantErr: "",
+		},
+		{
+			name:    "wrong account",
+			account: "222222222222",
+			wantErr: "error creating seed: refusing to create seed: caller arn:aws:sts::111111111111:assumed-role/sunlight/host1 " +
+				"belongs to account 111111111111, but the log's secret belongs to account 222222222222",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSM := &fakeSecretsManager{} //nolint:exhaustruct
+			clients := roleClients{
+				Regions: []regionalClient{{Region: "us-west-2", Client: testSM}},
+				STS:     fakeSTS{account: "111111111111"},
+			}
+			logConf := logConfig{Name: "test.tld/shard1", Inception: today, AccountID: tc.account} //nolint:exhaustruct
+			conf := &config{Logs: []logConfig{logConf}}                                            //nolint:exhaustruct
+
+			role, err := conf.SecretManager.role(logConf)
+			if err != nil {
+				t.Fatalf("role() = %q, but want success", err)
+			}
+
+			fetched := fetchSeeds(t.Context(), clients.Regions, []string{"terraform"}, "")
+
+			seed, _, err := getOrCreateSeed(t.Context(), "terraform", logConf, conf, newAWSStore(clients, conf.SecretManager, logConf, role.AccountID), fetched["terraform"], now)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("getOrCreateSeed() = %v, but want error %q", err, tc.wantErr)
+				}
+
+				if _, ok := testSM.lookup("terraform"); ok {
+					t.Error("getOrCreateSeed() stored a seed in the wrong account")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("getOrCreateSeed() = %q, but want success", err)
+			}
+
+			if len(seed) != seedLen {
+				t.Errorf("getOrCreateSeed() created a seed of length %d, but want %d", len(seed), seedLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// The ways of handling secrets whose resource policies allow principals outside
+// AllowedPrincipals to change or delete them.
+const (
+	// laxPolicyWarn logs a warning and carries on. It is the default.
+	laxPolicyWarn = "warn"
+	// laxPolicyFail refuses to write the seed.
+	laxPolicyFail = "fail"
+)
+
+// awsManagedKeyAlias is the alias of the AWS managed KMS key which Secrets
+// Manager uses for secrets which aren't configured with a customer managed key.
+const awsManagedKeyAlias = "alias/aws/secretsmanager"
+
+// dangerousActions are the actions which would let a principal change or
+// destroy a log's seed.
+var dangerousActions = []string{"secretsmanager:DeleteSecret", "secretsmanager:PutSecretValue"}
+
+// checkLaxPolicyMode returns an error if the LaxPolicy setting is not one we
+// know.
+func (smc secretManagerConfig) checkLaxPolicyMode() error {
+	switch smc.LaxPolicy {
+	case "", laxPolicyWarn, laxPolicyFail:
+		return nil
+	default:
+		return fmt.Errorf("unknown laxpolicy %q: must be %s or %s", smc.LaxPolicy, laxPolicyWarn, laxPolicyFail)
+	}
+}
+
+// checkSecretSafety checks the metadata of the secret with the given ID before
+// we write the seed it holds. It fails if the secret is scheduled for deletion.
+// It warns about, or fails on, resource policies which allow principals other
+// than AllowedPrincipals to change or delete the secret. It also logs whether
+// the secret is encrypted with a customer managed KMS key.
+func checkSecretSafety(ctx context.Context, smClient SecretsManager, id string, smc secretManagerConfig) error {
+	desc, err := smClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(id)})
+	if err != nil {
+		return fmt.Errorf("describing secret %q: %w", id, classifyError(err))
+	}
+
+	if desc.DeletedDate != nil {
+		return fmt.Errorf("secret %q was deleted on %s: %w", id, desc.DeletedDate.UTC().Format(time.RFC3339), errSecretScheduledForDeletion)
+	}
+
+	if desc.KmsKeyId == nil || aws.ToString(desc.KmsKeyId) == awsManagedKeyAlias {
+		log.Printf("Secret %q is encrypted with the AWS managed KMS key %s", id, awsManagedKeyAlias)
+	} else {
+		log.Printf("Secret %q is encrypted with the customer managed KMS key %s", id, aws.ToString(desc.KmsKeyId))
+	}
+
+	res, err := smClient.GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{SecretId: aws.String(id)})
+	if err != nil {
+		return fmt.Errorf("getting resource policy of secret %q: %w", id, classifyError(err))
+	}
+
+	if res.ResourcePolicy == nil {
+		return nil
+	}
+
+	problems, err := laxPolicyProblems(aws.ToString(res.ResourcePolicy), smc.AllowedPrincipals)
+	if err != nil {
+		return fmt.Errorf("parsing resource policy of secret %q: %w", id, err)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	if smc.LaxPolicy == laxPolicyFail {
+		return fmt.Errorf("resource policy of secret %q is too lax: %w", id, errors.Join(problems...))
+	}
+
+	for _, problem := range problems {
+		log.Printf("Warning: resource policy of secret %q is too lax: %v", id, problem)
+	}
+
+	return nil
+}
+
+// iamPolicy is the subset of an IAM policy document which we need to find out
+// who may perform which actions.
+type iamPolicy struct {
+	Statement iamStatements
+}
+
+// iamStatement is a single statement of an IAM policy document.
+type iamStatement struct {
+	Sid          string
+	Effect       string
+	Principal    json.RawMessage
+	NotPrincipal json.RawMessage
+	Action       stringOrSlice
+	NotAction    stringOrSlice
+}
+
+// iamStatements is the Statement of an IAM policy document, which may be a
+// single statement or a list of them.
+type iamStatements []iamStatement
+
+func (s *iamStatements) UnmarshalJSON(data []byte) error {
+	var one iamStatement
+	if json.Unmarshal(data, &one) == nil {
+		*s = iamStatements{one}
+
+		return nil
+	}
+
+	var many []iamStatement
+	err := json.Unmarshal(data, &many)
+	if err != nil {
+		return fmt.Errorf("parsing Statement: %w", err)
+	}
+
+	*s = many
+
+	return nil
+}
+
+// stringOrSlice is an IAM policy element which may be a single string or a
+// list of them.
+type stringOrSlice []string
+
+func (s *stringOrSlice) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*s = stringOrSlice{one}
+
+		return nil
+	}
+
+	var many []string
+	err := json.Unmarshal(data, &many)
+	if err != nil {
+		return fmt.Errorf("parsing string or list of strings: %w", err)
+	}
+
+	*s = many
+
+	return nil
+}
+
+// laxPolicyProblems returns a problem for every dangerous action which the
+// resource policy allows to principals outside allowed. It errs on the side of
+// reporting problems: conditions are ignored, and statements with NotAction or
+// NotPrincipal are assumed to allow whatever they don't exclude.
+func laxPolicyProblems(policy string, allowed []string) ([]error, error) {
+	var doc iamPolicy
+	err := json.Unmarshal([]byte(policy), &doc)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var problems []error
+	for i, stmt := range doc.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+
+		name := stmt.Sid
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		principals, err := policyPrincipals(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("statement %s: %w", name, err)
+		}
+
+		var outsiders []string
+		for _, principal := range principals {
+			if !slices.Contains(allowed, principal) {
+				outsiders = append(outsiders, principal)
+			}
+		}
+
+		if len(outsiders) == 0 {
+			continue
+		}
+
+		for _, action := range dangerousActions {
+			if stmt.allows(action) {
+				problems = append(problems, fmt.Errorf("statement %s allows %s to %s", name, action, strings.Join(outsiders, ", ")))
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// allows reports whether the statement's Action or NotAction covers action.
+func (stmt iamStatement) allows(action string) bool {
+	if len(stmt.NotAction) > 0 {
+		return !slices.ContainsFunc(stmt.NotAction, func(pattern string) bool { return matchIAMAction(pattern, action) })
+	}
+
+	return slices.ContainsFunc(stmt.Action, func(pattern string) bool { return matchIAMAction(pattern, action) })
+}
+
+// matchIAMAction reports whether an IAM action pattern, which may contain the
+// wildcards "*" and "?", matches action. Actions are case-insensitive.
+func matchIAMAction(pattern string, action string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(action))
+
+	return err == nil && ok
+}
+
+// policyPrincipals returns the principals to which a statement applies, such as
+// "arn:aws:iam::123456789012:role/sunlight" or "*". A statement with
+// NotPrincipal applies to everyone but the principals it lists, so it counts as
+// "*".
+func policyPrincipals(stmt iamStatement) ([]string, error) {
+	if len(stmt.NotPrincipal) > 0 {
+		return []string{"*"}, nil
+	}
+
+	if len(stmt.Principal) == 0 {
+		return nil, nil
+	}
+
+	var everyone string
+	if json.Unmarshal(stmt.Principal, &everyone) == nil {
+		return []string{everyone}, nil
+	}
+
+	var byType map[string]stringOrSlice
+	err := json.Unmarshal(stmt.Principal, &byType)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Principal: %w", err)
+	}
+
+	var principals []string
+	for _, typ := range slices.Sorted(maps.Keys(byType)) {
+		principals = append(principals, byType[typ]...)
+	}
+
+	return principals, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLaxPolicyProblems(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		policy  string
+		allowed []string
+		want    []string
+	}{
+		{
+			name:    "lax",
+			policy:  laxPolicy,
+			allowed: []string{"arn:aws:iam::123456789012:role/terraform"},
+			want:    []string{"statement Everyone allows secretsmanager:PutSecretValue to arn:aws:iam::123456789012:root"},
+		},
+		{
+			name:    "no allowlist",
+			policy:  laxPolicy,
+			allowed: nil,
+			want: []string{
+				"statement Terraform allows secretsmanager:DeleteSecret to arn:aws:iam::123456789012:role/terraform",
+				"statement Terraform allows secretsmanager:PutSecretValue to arn:aws:iam::123456789012:role/terraform",
+				"statement Everyone allows secretsmanager:PutSecretValue to arn:aws:iam::123456789012:root",
+			},
+		},
+		{
+			name:    "everyone allowed",
+			policy:  laxPolicy,
+			allowed: []string{"arn:aws:iam::123456789012:role/terraform", "arn:aws:iam::123456789012:root"},
+			want:    nil,
+		},
+		{
+			name:    "read only",
+			policy:  `{"Statement": {"Effect": "Allow", "Principal": "*", "Action": "secretsmanager:GetSecretValue"}}`,
+			allowed: nil,
+			want:    nil,
+		},
+		{
+			name:    "deny",
+			policy:  `{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "secretsmanager:DeleteSecret"}}`,
+			allowed: nil,
+			want:    nil,
+		},
+		{
+			name:    "not action",
+			policy:  `{"Statement": {"Effect": "Allow", "Principal": "*", "NotAction": "secretsmanager:Delete*"}}`,
+			allowed: nil,
+			want:    []string{"statement #1 allows secretsmanager:PutSecretValue to *"},
+		},
+		{
+			name:    "not principal",
+			policy:  `{"Statement": [{"Effect": "Allow", "NotPrincipal": {"AWS": "arn:aws:iam::123456789012:role/ops"}, "Action": "SecretsManager:DeleteSecret"}]}`,
+			allowed: []string{"arn:aws:iam::123456789012:role/ops"},
+			want:    []string{"statement #1 allows secretsmanager:DeleteSecret to *"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := laxPolicyProblems(tc.policy, tc.allowed)
+			if err != nil {
+				t.Fatalf("laxPolicyProblems() = %q, but want success", err)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("laxPolicyProblems() = %q, but want %q", got, tc.want)
+			}
+
+			for i := range got {
+				if got[i].Error() != tc.want[i] {
+					t.Errorf("laxPolicyProblems() problem %d = %q, but want %q", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSecretSafety(t *testing.T) {
+	t.Parallel()
+
+	testSM := &fakeSecretsManager{} //nolint:exhaustruct
+	allowed := []string{"arn:aws:iam::123456789012:role/terraform"}
+
+	for _, tc := range []struct {
+		name    string
+		id      string
+		smc     secretManagerConfig
+		wantErr string
+	}{
+		{
+			name:    "safe",
+			id:      "real",
+			smc:     secretManagerConfig{LaxPolicy: laxPolicyFail}, //nolint:exhaustruct
+			wantErr: "",
+		},
+		{
+			name:    "scheduled for deletion",
+			id:      "doomed",
+			smc:     secretManagerConfig{}, //nolint:exhaustruct
+			wantErr: `secret "doomed" was deleted on 2025-01-01T00:00:00Z: secret is scheduled for deletion`,
+		},
+		{
+			name:    "lax policy warning",
+			id:      "lax",
+			smc:     secretManagerConfig{AllowedPrincipals: allowed}, //nolint:exhaustruct
+			wantErr: "",
+		},
+		{
+			name:    "lax policy failure",
+			id:      "lax",
+			smc:     secretManagerConfig{AllowedPrincipals: allowed, LaxPolicy: laxPolicyFail}, //nolint:exhaustruct
+			wantErr: `resource policy of secret "lax" is too lax: statement Everyone allows secretsmanager:PutSecretValue`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkSecretSafety(t.Context(), testSM, tc.id, tc.smc)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("checkSecretSafety(%q) = nil, but want error %q", tc.id, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("checkSecretSafety(%q) = %q, but want error %q", tc.id, err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("checkSecretSafety(%q) = %q, but want success", tc.id, err)
+			}
+		})
+	}
+}
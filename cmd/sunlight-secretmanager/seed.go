@@ -3,10 +3,18 @@ package main
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
 )
 
 // All Sunlight seeds must be exactly 32 bytes.
@@ -16,11 +24,118 @@ const seedLen = 32
 // or equivalent implementation. This makes it easier to mock for testing.
 type SecretsManager interface {
 	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
 	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	ListSecretVersionIds(ctx context.Context, params *secretsmanager.ListSecretVersionIdsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretVersionIdsOutput, error)
+	UpdateSecretVersionStage(ctx context.Context, params *secretsmanager.UpdateSecretVersionStageInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error)
+	GetResourcePolicy(ctx context.Context, params *secretsmanager.GetResourcePolicyInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetResourcePolicyOutput, error)
 }
 
-// fetchSeed retrieves a secret value from the provided SecretsManager.
-func fetchSeed(ctx context.Context, smClient SecretsManager, id string) ([]byte, error) {
+// The errors which classifyError wraps around Secrets Manager errors, so that
+// callers can tell them apart without knowing about AWS error types.
+var (
+	// errSecretNotFound means that the secret does not exist at all, as
+	// opposed to existing without a value. It is the only error after which a
+	// new secret may be created.
+	errSecretNotFound = errors.New("secret does not exist")
+	// errSecretScheduledForDeletion means that the secret has been deleted,
+	// but can still be restored.
+	errSecretScheduledForDeletion = errors.New("secret is scheduled for deletion; restore it with \"aws secretsmanager restore-secret\" rather than creating a new seed")
+	// errAccessDenied means that our credentials aren't allowed to perform
+	// the request.
+	errAccessDenied = errors.New("access denied; check that this host's IAM role allows secretsmanager:GetSecretValue, " +
+		"secretsmanager:DescribeSecret, secretsmanager:GetResourcePolicy, secretsmanager:ListSecretVersionIds, secretsmanager:UpdateSecretVersionStage, " +
+		"secretsmanager:PutSecretValue and secretsmanager:CreateSecret on the secret")
+	// errKMSDecrypt means that Secrets Manager couldn't use the secret's KMS
+	// key on our behalf.
+	errKMSDecrypt = errors.New("KMS decryption failed; check that the secret's KMS key is enabled and that its key " +
+		"policy and this host's IAM role allow kms:Decrypt and kms:GenerateDataKey")
+	// errSecretExists means that the secret, or a version of it with the same
+	// ClientRequestToken, was created by someone else in the meantime.
+	errSecretExists = errors.New("secret was initialized concurrently")
+	// errTransient means that the request failed in a way which may not
+	// happen again, such as throttling, a network failure or a server error.
+	errTransient = errors.New("transient error")
+)
+
+// classifyError wraps err, which was returned by Secrets Manager, in whichever
+// of the errors above describes it. Errors which don't match any of them are
+// returned as they are.
+func classifyError(err error) error {
+	var (
+		notFound   *types.ResourceNotFoundException
+		exists     *types.ResourceExistsException
+		invalid    *types.InvalidRequestException
+		decryption *types.DecryptionFailure
+		internal   *types.InternalServiceError
+		apiErr     smithy.APIError
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &notFound):
+		return fmt.Errorf("%w: %w", errSecretNotFound, err)
+	case errors.As(err, &exists):
+		return fmt.Errorf("%w: %w", errSecretExists, err)
+	case errors.As(err, &invalid) && strings.Contains(invalid.ErrorMessage(), "marked for deletion"):
+		return fmt.Errorf("%w: %w", errSecretScheduledForDeletion, err)
+	case errors.As(err, &decryption):
+		return fmt.Errorf("%w: %w", errKMSDecrypt, err)
+	case errors.As(err, &apiErr) && strings.HasPrefix(apiErr.ErrorCode(), "KMS"):
+		return fmt.Errorf("%w: %w", errKMSDecrypt, err)
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException":
+		// Secrets Manager reports KMS permission problems as AccessDenied too.
+		if strings.Contains(apiErr.ErrorMessage(), "KMS") {
+			return fmt.Errorf("%w: %w", errKMSDecrypt, err)
+		}
+
+		return fmt.Errorf("%w: %w", errAccessDenied, err)
+	case errors.As(err, &internal),
+		retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary:
+		return fmt.Errorf("%w: %w", errTransient, err)
+	default:
+		return err
+	}
+}
+
+// transientRetryDelays are the delays before each retry of an operation which
+// failed with errTransient. They come on top of the AWS SDK's own retries.
+var transientRetryDelays = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+
+// retryTransient calls op until it returns an error other than errTransient,
+// sleeping for each of delays in turn, and returns its last results.
+func retryTransient[T any](ctx context.Context, delays []time.Duration, op func() (T, error)) (T, error) {
+	res, err := op()
+
+	for _, delay := range delays {
+		if !errors.Is(err, errTransient) {
+			break
+		}
+
+		log.Printf("Retrying in %s after %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return res, err
+		case <-time.After(delay):
+		}
+
+		res, err = op()
+	}
+
+	return res, err
+}
+
+// fetchSeed retrieves a secret value from the provided SecretsManager, and
+// decodes it with the given encoding (see decodeSeed). If the secret exists but
+// has no value yet, as is the case for secrets created by Terraform, it returns
+// an empty seed. If the secret does not exist, it returns an error wrapping
+// errSecretNotFound. It also returns the ID of the version which holds the
+// seed, which is empty if the secret has no value.
+func fetchSeed(ctx context.Context, smClient SecretsManager, id string, encoding string) ([]byte, string, error) {
 	req := &secretsmanager.GetSecretValueInput{
 		SecretId:     aws.String(id),
 		VersionStage: nil,
@@ -28,36 +143,148 @@ func fetchSeed(ctx context.Context, smClient SecretsManager, id string) ([]byte,
 	}
 
 	res, err := smClient.GetSecretValue(ctx, req)
+	err = classifyError(err)
+
+	// GetSecretValue reports secrets with no AWSCURRENT version as not found,
+	// just like secrets which don't exist, so DescribeSecret tells them apart.
+	if errors.Is(err, errSecretNotFound) {
+		return nil, "", describeEmptySecret(ctx, smClient, id)
+	} else if err != nil {
+		return nil, "", fmt.Errorf("retrieving secret %q: %w", id, err)
+	}
+
+	seed, err := decodeSeed(id, encoding, res.SecretBinary, res.SecretString)
+
+	return seed, aws.ToString(res.VersionId), err
+}
+
+// describeEmptySecret is called when GetSecretValue can't find the secret with
+// the given ID. It returns nil if the secret exists but has no versions at all,
+// an error wrapping errSecretNotFound if it doesn't exist, and another error if
+// it has versions but none of them is current.
+func describeEmptySecret(ctx context.Context, smClient SecretsManager, id string) error {
+	req := &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(id),
+	}
+
+	res, err := smClient.DescribeSecret(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("retrieving secret %q: %w", id, err)
+		return fmt.Errorf("describing secret %q: %w", id, classifyError(err))
 	}
 
-	return res.SecretBinary, nil
+	if len(res.VersionIdsToStages) != 0 {
+		return fmt.Errorf("secret %q has %d versions, but none of them is current", id, len(res.VersionIdsToStages))
+	}
+
+	return nil
 }
 
-// createSeed generates a new 32-byte random string and stores it as a new
-// secret with the given ID.
-func createSeed(ctx context.Context, smClient SecretsManager, id string) ([]byte, error) {
+// newSeed generates a new 32-byte random seed.
+func newSeed() []byte {
 	// crypto/rand.Read is documented to always succeed.
 	seed := make([]byte, seedLen)
 	_, _ = rand.Read(seed)
 
-	req := &secretsmanager.CreateSecretInput{
-		Name:                        aws.String(id),
-		AddReplicaRegions:           nil,
-		ClientRequestToken:          nil,
-		Description:                 nil,
-		ForceOverwriteReplicaSecret: false,
-		KmsKeyId:                    nil,
-		SecretBinary:                seed,
-		SecretString:                nil,
-		Tags:                        nil,
+	return seed
+}
+
+// seedRequestToken returns the idempotency token to use for requests which
+// store the first version of the secret with the given ID. It is a UUID derived
+// from the secret ID, so that every host initializing the same secret uses the
+// same token. Secrets Manager then rejects all but the first of their requests,
+// rather than letting a later request replace the first host's seed.
+func seedRequestToken(id string) string {
+	h := sha256.Sum256([]byte("sunlight-secretmanager seed\n" + id))
+
+	var uuid [16]byte
+	copy(uuid[:], h[:])
+
+	uuid[6] = uuid[6]&0x0f | 0x80 // version 8, for custom UUIDs
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 9562 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+// initSeed stores seed in the secret with the given ID, with putSeed if the
+// secret exists or with createSeed and the given metadata if it doesn't. If
+// another host stored a seed first, it uses that one instead. Either way, it
+// returns the seed which is actually stored, as read back from Secrets Manager,
+// and the ID of the version which holds it.
+func initSeed(ctx context.Context, smClient SecretsManager, id string, exists bool, meta secretMetadata, seed []byte) ([]byte, string, error) {
+	var err error
+	if exists {
+		err = putSeed(ctx, smClient, id, meta.Encoding, seed)
+	} else {
+		err = createSeed(ctx, smClient, id, meta, seed)
+	}
+
+	if errors.Is(err, errSecretExists) {
+		log.Printf("Secret %q was initialized concurrently by another host, using its seed: %v", id, err)
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	stored, versionID, err := fetchSeed(ctx, smClient, id, meta.Encoding)
+	if err != nil {
+		return nil, "", fmt.Errorf("re-fetching secret %q: %w", id, err)
+	}
+
+	if len(stored) == 0 {
+		return nil, "", fmt.Errorf("secret %q is still empty after storing a seed in it", id)
+	}
+
+	return stored, versionID, nil
+}
+
+// createSeed stores seed as a new secret with the given ID and metadata,
+// tagged with the log ID derived from the seed. It is for secrets which don't
+// exist yet. It fails with errSecretExists if the secret has been created
+// meanwhile. If the secret is replicated, it waits for all of its replicas to
+// be in sync.
+func createSeed(ctx context.Context, smClient SecretsManager, id string, meta secretMetadata, seed []byte) error {
+	keys, err := deriveKeys(seed)
+	if err != nil {
+		return fmt.Errorf("deriving keys from new seed: %w", err)
+	}
+
+	req := meta.createSecretInput(id, seed, keys.logIDBase64())
+
+	_, err = smClient.CreateSecret(ctx, req)
+	if err != nil {
+		return fmt.Errorf("creating secret %q: %w", id, classifyError(err))
+	}
+
+	if len(meta.ReplicaRegions) > 0 {
+		err = waitForReplication(ctx, smClient, id, replicationPollInterval, replicationTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putSeed stores seed, with the given encoding, as the first version of the
+// existing secret with the given ID. It is for secrets which were created
+// without a value, such as by Terraform, so it leaves the secret's metadata
+// alone. It fails with errSecretExists if another host has put a different
+// seed meanwhile.
+func putSeed(ctx context.Context, smClient SecretsManager, id string, encoding string, seed []byte) error {
+	binary, str := encodeSeed(encoding, seed)
+
+	req := &secretsmanager.PutSecretValueInput{
+		SecretId:           aws.String(id),
+		ClientRequestToken: aws.String(seedRequestToken(id)),
+		RotationToken:      nil,
+		SecretBinary:       binary,
+		SecretString:       str,
+		VersionStages:      nil,
 	}
 
-	_, err := smClient.CreateSecret(ctx, req)
+	_, err := smClient.PutSecretValue(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("creating secret %q: %w", id, err)
+		return fmt.Errorf("putting value of secret %q: %w", id, classifyError(err))
 	}
 
-	return seed, nil
+	return nil
 }
@@ -5,45 +5,216 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
 )
 
-type fakeSecretsManager struct{}
+// fakeSecretsManager serves canned secrets, except that seeds stored by
+// CreateSecret and PutSecretValue, and versions pinned by
+// UpdateSecretVersionStage, are remembered and served from then on. Seeds stored
+// as SecretString are kept in storedStrings. If batchDenied is set,
+// BatchGetSecretValue is not permitted.
+type fakeSecretsManager struct {
+	mu            sync.Mutex
+	stored        map[string][]byte
+	storedStrings map[string]string
+	pinned        map[string]string
+	created       map[string]*secretsmanager.CreateSecretInput
+	batchDenied   bool
+	batches       int
+}
 
 var _ SecretsManager = (*fakeSecretsManager)(nil)
 
+// store remembers the seed stored in the secret with the given ID.
+func (sm *fakeSecretsManager) store(id string, seed []byte) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.stored == nil {
+		sm.stored = make(map[string][]byte)
+	}
+
+	sm.stored[id] = seed
+}
+
+// storeString remembers the SecretString stored in the secret with the given
+// ID.
+func (sm *fakeSecretsManager) storeString(id string, str string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.storedStrings == nil {
+		sm.storedStrings = make(map[string]string)
+	}
+
+	sm.storedStrings[id] = str
+}
+
+// lookupString returns the SecretString stored in the secret with the given
+// ID, if any.
+func (sm *fakeSecretsManager) lookupString(id string) (string, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	str, ok := sm.storedStrings[id]
+
+	return str, ok
+}
+
+// has reports whether a seed has been stored in the secret with the given ID,
+// either way.
+func (sm *fakeSecretsManager) has(id string) bool {
+	_, binary := sm.lookup(id)
+	_, str := sm.lookupString(id)
+
+	return binary || str
+}
+
+// lookup returns the seed stored in the secret with the given ID, if any.
+func (sm *fakeSecretsManager) lookup(id string) ([]byte, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	seed, ok := sm.stored[id]
+
+	return seed, ok
+}
+
 func (sm *fakeSecretsManager) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
 	if params.SecretId == nil || len(*params.SecretId) == 0 {
 		return nil, errors.New("incomplete request")
 	}
 
+	if seed, ok := sm.lookup(*params.SecretId); ok {
+		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+			Name:         params.SecretId,
+			SecretBinary: seed,
+			VersionId:    aws.String(seedRequestToken(*params.SecretId)),
+		}, nil
+	}
+
+	if str, ok := sm.lookupString(*params.SecretId); ok {
+		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+			Name:         params.SecretId,
+			SecretString: aws.String(str),
+			VersionId:    aws.String(seedRequestToken(*params.SecretId)),
+		}, nil
+	}
+
 	switch *params.SecretId {
 	case "missing":
 		return nil, fmt.Errorf("secret %q not found", *params.SecretId)
+	case "absent", "terraform", "stale":
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")} //nolint:exhaustruct
+	case "denied":
+		return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized", Fault: smithy.FaultClient}
+	case "deleted":
+		return nil, &types.InvalidRequestException{Message: aws.String("You can't perform this operation on the secret because it was marked for deletion.")} //nolint:exhaustruct
 	case "empty":
 		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
 			Name: aws.String("empty"),
 		}, nil
-	case "real":
+	case "real", "pinned", "rotating":
+		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+			Name:         params.SecretId,
+			SecretBinary: []byte("hello world"),
+			VersionId:    aws.String("v1"),
+		}, nil
+	case "changed", "replaced":
 		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
-			Name:         aws.String("real"),
+			Name:         params.SecretId,
 			SecretBinary: []byte("hello world"),
+			VersionId:    aws.String("v2"),
+		}, nil
+	case "base64":
+		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+			Name:         params.SecretId,
+			SecretString: aws.String("aGVsbG8gd29ybGQ="),
+			VersionId:    aws.String("v1"),
+		}, nil
+	case "hex":
+		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+			Name:         params.SecretId,
+			SecretString: aws.String("68656c6c6f20776f726c64"),
+			VersionId:    aws.String("v1"),
+		}, nil
+	case "both":
+		return &secretsmanager.GetSecretValueOutput{ //nolint:exhaustruct
+			Name:         params.SecretId,
+			SecretBinary: []byte("hello world"),
+			SecretString: aws.String("aGVsbG8gd29ybGQ="),
+			VersionId:    aws.String("v1"),
 		}, nil
 	default:
 		return nil, fmt.Errorf("secret %q not recognized", *params.SecretId)
 	}
 }
 
+func (sm *fakeSecretsManager) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	if sm.batchDenied {
+		return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform secretsmanager:BatchGetSecretValue", Fault: smithy.FaultClient}
+	}
+
+	sm.mu.Lock()
+	sm.batches++
+	sm.mu.Unlock()
+
+	return batchGetSecretValue(ctx, sm, params)
+}
+
+// batchGetSecretValue implements BatchGetSecretValue with smClient's
+// GetSecretValue, reporting errors for each secret like Secrets Manager does.
+func batchGetSecretValue(ctx context.Context, smClient SecretsManager, params *secretsmanager.BatchGetSecretValueInput) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	if len(params.SecretIdList) > batchSize {
+		return nil, &types.InvalidParameterException{Message: aws.String("too many secrets")} //nolint:exhaustruct
+	}
+
+	res := &secretsmanager.BatchGetSecretValueOutput{} //nolint:exhaustruct
+	for _, id := range params.SecretIdList {
+		value, err := smClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)}) //nolint:exhaustruct
+
+		var apiErr smithy.APIError
+		switch {
+		case errors.As(err, &apiErr):
+			res.Errors = append(res.Errors, types.APIErrorType{ //nolint:exhaustruct
+				ErrorCode: aws.String(apiErr.ErrorCode()),
+				Message:   aws.String(apiErr.ErrorMessage()),
+				SecretId:  aws.String(id),
+			})
+		case err != nil:
+			res.Errors = append(res.Errors, types.APIErrorType{ //nolint:exhaustruct
+				ErrorCode: aws.String("ValidationException"),
+				Message:   aws.String(err.Error()),
+				SecretId:  aws.String(id),
+			})
+		default:
+			res.SecretValues = append(res.SecretValues, types.SecretValueEntry{ //nolint:exhaustruct
+				Name:         value.Name,
+				SecretBinary: value.SecretBinary,
+				VersionId:    value.VersionId,
+			})
+		}
+	}
+
+	return res, nil
+}
+
 func (sm *fakeSecretsManager) CreateSecret(_ context.Context, params *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
-	if params.Name == nil || len(*params.Name) == 0 || len(params.SecretBinary) == 0 {
+	if params.Name == nil || len(*params.Name) == 0 || (len(params.SecretBinary) == 0 && params.SecretString == nil) {
 		return nil, errors.New("incomplete request")
 	}
 
-	if params.SecretString != nil {
+	if len(params.SecretBinary) != 0 && params.SecretString != nil {
 		return nil, errors.New("can't specify both SecretBinary and SecretString")
 	}
 
@@ -51,57 +222,357 @@ func (sm *fakeSecretsManager) CreateSecret(_ context.Context, params *secretsman
 		return nil, fmt.Errorf("error 500 creating secret %q", *params.Name)
 	}
 
-	if len(params.SecretBinary) != 32 {
+	if params.SecretString == nil && len(params.SecretBinary) != 32 {
 		return nil, fmt.Errorf("bad seed length: %d", len(params.SecretBinary))
 	}
 
+	if sm.has(*params.Name) {
+		return nil, &types.ResourceExistsException{Message: aws.String("The operation failed because the secret already exists.")} //nolint:exhaustruct
+	}
+
+	if params.SecretString != nil {
+		sm.storeString(*params.Name, *params.SecretString)
+	} else {
+		sm.store(*params.Name, params.SecretBinary)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.created == nil {
+		sm.created = make(map[string]*secretsmanager.CreateSecretInput)
+	}
+
+	sm.created[*params.Name] = params
+
 	return &secretsmanager.CreateSecretOutput{ //nolint:exhaustruct
 		ARN:       aws.String(*params.Name + "-123456"),
 		Name:      params.Name,
-		VersionId: aws.String("919108f7-52d1-4320-9bac-f847db4148a8"),
+		VersionId: params.ClientRequestToken,
+	}, nil
+}
+
+func (sm *fakeSecretsManager) PutSecretValue(_ context.Context, params *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	if params.SecretId == nil || len(*params.SecretId) == 0 || (len(params.SecretBinary) == 0 && params.SecretString == nil) || params.ClientRequestToken == nil {
+		return nil, errors.New("incomplete request")
+	}
+
+	if len(params.SecretBinary) != 0 && params.SecretString != nil {
+		return nil, errors.New("can't specify both SecretBinary and SecretString")
+	}
+
+	switch *params.SecretId {
+	case "absent":
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")} //nolint:exhaustruct
+	case "error":
+		return nil, fmt.Errorf("error 500 putting value of secret %q", *params.SecretId)
+	}
+
+	if params.SecretString != nil {
+		sm.storeString(*params.SecretId, *params.SecretString)
+	} else if len(params.SecretBinary) != 32 {
+		return nil, fmt.Errorf("bad seed length: %d", len(params.SecretBinary))
+	} else {
+		sm.store(*params.SecretId, params.SecretBinary)
+	}
+
+	return &secretsmanager.PutSecretValueOutput{ //nolint:exhaustruct
+		ARN:           aws.String(*params.SecretId + "-123456"),
+		Name:          params.SecretId,
+		VersionId:     params.ClientRequestToken,
+		VersionStages: []string{"AWSCURRENT"},
 	}, nil
 }
 
+func (sm *fakeSecretsManager) DescribeSecret(_ context.Context, params *secretsmanager.DescribeSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	if params.SecretId == nil || len(*params.SecretId) == 0 {
+		return nil, errors.New("incomplete request")
+	}
+
+	res := &secretsmanager.DescribeSecretOutput{ //nolint:exhaustruct
+		Name: params.SecretId,
+	}
+
+	if sm.has(*params.SecretId) {
+		res.VersionIdsToStages = map[string][]string{seedRequestToken(*params.SecretId): {currentStage}}
+	} else {
+		switch *params.SecretId {
+		case "absent":
+			return nil, &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")} //nolint:exhaustruct
+		case "terraform":
+		case "stale":
+			res.VersionIdsToStages = map[string][]string{"v1": {"AWSPREVIOUS"}}
+		case "real":
+			res.VersionIdsToStages = map[string][]string{"v1": {currentStage}}
+		case "pinned":
+			res.VersionIdsToStages = map[string][]string{"v1": {currentStage, pinnedStage}}
+		case "rotating":
+			res.RotationEnabled = aws.Bool(true)
+			res.VersionIdsToStages = map[string][]string{"v1": {currentStage}}
+		case "changed":
+			res.VersionIdsToStages = map[string][]string{"v1": {pinnedStage}, "v2": {currentStage}}
+		case "replaced":
+			res.VersionIdsToStages = map[string][]string{"v1": {}, "v2": {currentStage}}
+		case "lax":
+			res.KmsKeyId = aws.String("arn:aws:kms:us-west-2:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab")
+			res.VersionIdsToStages = map[string][]string{"v1": {currentStage}}
+		case "doomed":
+			res.DeletedDate = aws.Time(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+			res.VersionIdsToStages = map[string][]string{"v1": {currentStage}}
+		default:
+			return nil, fmt.Errorf("secret %q not recognized", *params.SecretId)
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// Replication to regions whose names start with "fail" fails, and to
+	// regions whose names start with "slow" never finishes.
+	if created, ok := sm.created[*params.SecretId]; ok {
+		for _, replica := range created.AddReplicaRegions {
+			status := types.StatusTypeInSync
+			if strings.HasPrefix(*replica.Region, "fail") {
+				status = types.StatusTypeFailed
+			} else if strings.HasPrefix(*replica.Region, "slow") {
+				status = types.StatusTypeInProgress
+			}
+
+			res.ReplicationStatus = append(res.ReplicationStatus, types.ReplicationStatusType{ //nolint:exhaustruct
+				Region:        replica.Region,
+				Status:        status,
+				StatusMessage: aws.String("Replication " + string(status)),
+			})
+		}
+	}
+
+	if pinned, ok := sm.pinned[*params.SecretId]; ok {
+		res.VersionIdsToStages[pinned] = append(res.VersionIdsToStages[pinned], pinnedStage)
+	}
+
+	return res, nil
+}
+
+// ListSecretVersionIds lists the versions returned by DescribeSecret, as if
+// they had been created in the order of their IDs.
+func (sm *fakeSecretsManager) ListSecretVersionIds(ctx context.Context, params *secretsmanager.ListSecretVersionIdsInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretVersionIdsOutput, error) {
+	desc, err := sm.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: params.SecretId})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &secretsmanager.ListSecretVersionIdsOutput{ //nolint:exhaustruct
+		Name: params.SecretId,
+	}
+
+	created := time.Date(2024, time.August, 7, 0, 0, 0, 0, time.UTC)
+	for _, versionID := range slices.Sorted(maps.Keys(desc.VersionIdsToStages)) {
+		res.Versions = append(res.Versions, types.SecretVersionsListEntry{ //nolint:exhaustruct
+			VersionId:     aws.String(versionID),
+			VersionStages: desc.VersionIdsToStages[versionID],
+			CreatedDate:   aws.Time(created),
+		})
+		created = created.Add(time.Hour)
+	}
+
+	return res, nil
+}
+
+func (sm *fakeSecretsManager) UpdateSecretVersionStage(_ context.Context, params *secretsmanager.UpdateSecretVersionStageInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	if params.SecretId == nil || aws.ToString(params.VersionStage) != pinnedStage || params.MoveToVersionId == nil {
+		return nil, errors.New("incomplete request")
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.pinned == nil {
+		sm.pinned = make(map[string]string)
+	}
+
+	sm.pinned[*params.SecretId] = *params.MoveToVersionId
+
+	return &secretsmanager.UpdateSecretVersionStageOutput{ //nolint:exhaustruct
+		Name: params.SecretId,
+	}, nil
+}
+
+// laxPolicy is a resource policy which lets a Terraform role manage the secret
+// and anyone in the account put new values in it.
+const laxPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "Terraform",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:role/terraform"},
+      "Action": "secretsmanager:*",
+      "Resource": "*"
+    },
+    {
+      "Sid": "Everyone",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::123456789012:root"]},
+      "Action": ["secretsmanager:GetSecretValue", "secretsmanager:Put*"],
+      "Resource": "*"
+    }
+  ]
+}`
+
+func (sm *fakeSecretsManager) GetResourcePolicy(_ context.Context, params *secretsmanager.GetResourcePolicyInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetResourcePolicyOutput, error) {
+	if params.SecretId == nil || len(*params.SecretId) == 0 {
+		return nil, errors.New("incomplete request")
+	}
+
+	res := &secretsmanager.GetResourcePolicyOutput{ //nolint:exhaustruct
+		Name: params.SecretId,
+	}
+
+	if *params.SecretId == "lax" {
+		res.ResourcePolicy = aws.String(laxPolicy)
+	}
+
+	return res, nil
+}
+
 func TestFetchSeed(t *testing.T) {
 	t.Parallel()
 
-	testSM := &fakeSecretsManager{}
+	testSM := &fakeSecretsManager{} //nolint:exhaustruct
 
 	for _, tc := range []struct {
-		name    string
-		secret  string
-		want    []byte
-		wantErr string
+		name     string
+		secret   string
+		encoding string
+		want     []byte
+		wantID   string
+		wantErr  string
 	}{
 		{
-			name:    "bad request",
-			secret:  "",
-			want:    nil,
-			wantErr: "incomplete request",
+			name:     "bad request",
+			secret:   "",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  "incomplete request",
 		},
 		{
-			name:    "missing secret",
-			secret:  "missing",
-			want:    nil,
-			wantErr: "retrieving secret",
+			name:     "missing secret",
+			secret:   "missing",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  "retrieving secret",
 		},
 		{
-			name:    "empty",
-			secret:  "empty",
-			want:    []byte{},
-			wantErr: "",
+			name:     "nonexistent secret",
+			secret:   "absent",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  "secret does not exist",
 		},
 		{
-			name:    "happy path",
-			secret:  "real",
-			want:    []byte("hello world"),
-			wantErr: "",
+			name:     "access denied",
+			secret:   "denied",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  "access denied; check that this host's IAM role allows",
+		},
+		{
+			name:     "scheduled for deletion",
+			secret:   "deleted",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  "secret is scheduled for deletion",
+		},
+		{
+			name:     "no current version",
+			secret:   "stale",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  "has 1 versions, but none of them is current",
+		},
+		{
+			name:     "neither value",
+			secret:   "empty",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  `secret "empty" has neither SecretBinary nor SecretString`,
+		},
+		{
+			name:     "both values",
+			secret:   "both",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  `secret "both" has both SecretBinary and SecretString`,
+		},
+		{
+			name:     "string with binary encoding",
+			secret:   "base64",
+			encoding: "",
+			want:     nil,
+			wantID:   "",
+			wantErr:  `secret "base64" has a SecretString, but encoding is binary`,
+		},
+		{
+			name:     "binary with base64 encoding",
+			secret:   "real",
+			encoding: "base64",
+			want:     nil,
+			wantID:   "",
+			wantErr:  `secret "real" has a SecretBinary, but encoding is base64`,
+		},
+		{
+			name:     "base64",
+			secret:   "base64",
+			encoding: "base64",
+			want:     []byte("hello world"),
+			wantID:   "v1",
+			wantErr:  "",
+		},
+		{
+			name:     "hex",
+			secret:   "hex",
+			encoding: "hex",
+			want:     []byte("hello world"),
+			wantID:   "v1",
+			wantErr:  "",
+		},
+		{
+			name:     "bad hex",
+			secret:   "base64",
+			encoding: "hex",
+			want:     nil,
+			wantID:   "",
+			wantErr:  `decoding SecretString of secret "base64" as hex`,
+		},
+		{
+			name:     "no versions",
+			secret:   "terraform",
+			encoding: "",
+			want:     []byte{},
+			wantID:   "",
+			wantErr:  "",
+		},
+		{
+			name:     "happy path",
+			secret:   "real",
+			encoding: "",
+			want:     []byte("hello world"),
+			wantID:   "v1",
+			wantErr:  "",
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := fetchSeed(t.Context(), testSM, tc.secret)
+			got, gotID, err := fetchSeed(t.Context(), testSM, tc.secret, tc.encoding)
 			if tc.wantErr != "" { //nolint:nestif
 				if err == nil {
 					t.Errorf("fetchSeed(%q) = %#v, but want error %q", tc.secret, got, tc.wantErr)
@@ -111,18 +582,223 @@ func TestFetchSeed(t *testing.T) {
 			} else {
 				if err != nil {
 					t.Errorf("fetchSeed(%q) = %#v, but want no error", tc.secret, err)
-				} else if !bytes.Equal(got, tc.want) {
-					t.Errorf("fetchSeed(%q) = %#v, want %#v", tc.secret, got, tc.want)
+				} else if !bytes.Equal(got, tc.want) || gotID != tc.wantID {
+					t.Errorf("fetchSeed(%q) = %#v from version %q, want %#v from version %q", tc.secret, got, gotID, tc.want, tc.wantID)
 				}
 			}
 		})
 	}
 }
 
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "not found",
+			err:  &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")}, //nolint:exhaustruct
+			want: errSecretNotFound,
+		},
+		{
+			name: "exists",
+			err:  &types.ResourceExistsException{Message: aws.String("The operation failed because the secret already exists.")}, //nolint:exhaustruct
+			want: errSecretExists,
+		},
+		{
+			name: "scheduled for deletion",
+			err:  &types.InvalidRequestException{Message: aws.String("You can't perform this operation on the secret because it was marked for deletion.")}, //nolint:exhaustruct
+			want: errSecretScheduledForDeletion,
+		},
+		{
+			name: "access denied",
+			err:  &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized", Fault: smithy.FaultClient},
+			want: errAccessDenied,
+		},
+		{
+			name: "KMS access denied",
+			err:  &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "Access to KMS is not allowed", Fault: smithy.FaultClient},
+			want: errKMSDecrypt,
+		},
+		{
+			name: "decryption failure",
+			err:  &types.DecryptionFailure{Message: aws.String("Secrets Manager can't decrypt the protected secret text using the provided KMS key.")}, //nolint:exhaustruct
+			want: errKMSDecrypt,
+		},
+		{
+			name: "throttling",
+			err:  &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded", Fault: smithy.FaultClient},
+			want: errTransient,
+		},
+		{
+			name: "internal error",
+			err:  &types.InternalServiceError{Message: aws.String("An error occurred on the server side.")}, //nolint:exhaustruct
+			want: errTransient,
+		},
+		{
+			name: "other",
+			err:  &types.InvalidParameterException{Message: aws.String("The parameter name or value is invalid.")}, //nolint:exhaustruct
+			want: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := classifyError(tc.err)
+			if !errors.Is(got, tc.err) {
+				t.Errorf("classifyError() = %q, which doesn't wrap %q", got, tc.err)
+			}
+
+			if tc.want != nil && !errors.Is(got, tc.want) {
+				t.Errorf("classifyError() = %q, but want it to wrap %q", got, tc.want)
+			} else if tc.want == nil && got != tc.err { //nolint:errorlint
+				t.Errorf("classifyError() = %q, but want %q unchanged", got, tc.err)
+			}
+		})
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	t.Parallel()
+
+	delays := []time.Duration{time.Millisecond, time.Millisecond}
+
+	for _, tc := range []struct {
+		name      string
+		failures  int
+		failure   error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", failures: 0, failure: nil, wantCalls: 1, wantErr: nil},
+		{name: "transient", failures: 2, failure: errTransient, wantCalls: 3, wantErr: nil},
+		{name: "too many transient", failures: 3, failure: errTransient, wantCalls: 3, wantErr: errTransient},
+		{name: "permanent", failures: 3, failure: errAccessDenied, wantCalls: 1, wantErr: errAccessDenied},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			got, err := retryTransient(t.Context(), delays, func() (int, error) {
+				calls++
+				if calls <= tc.failures {
+					return 0, fmt.Errorf("attempt %d: %w", calls, tc.failure)
+				}
+
+				return calls, nil
+			})
+
+			if calls != tc.wantCalls {
+				t.Errorf("retryTransient() made %d calls, but want %d", calls, tc.wantCalls)
+			}
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("retryTransient() = %q, but want error %q", err, tc.wantErr)
+				}
+			} else if err != nil || got != calls {
+				t.Errorf("retryTransient() = %d, %v, but want %d, nil", got, err, calls)
+			}
+		})
+	}
+}
+
 func TestCreateSeed(t *testing.T) {
 	t.Parallel()
 
-	testSM := &fakeSecretsManager{}
+	testSM := &fakeSecretsManager{} //nolint:exhaustruct
+
+	meta := secretMetadata{
+		KmsKeyID:       "alias/sunlight",
+		Description:    "Sunlight log seed",
+		Tags:           map[string]string{"team": "ct", logNameTag: "test.tld/shard1"},
+		ReplicaRegions: []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: ""}},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		id       string
+		replicas []replicaRegionConfig
+		wantErr  string
+	}{
+		{
+			name:     "bad request",
+			id:       "",
+			replicas: nil,
+			wantErr:  "incomplete request",
+		},
+		{
+			name:     "error",
+			id:       "error",
+			replicas: nil,
+			wantErr:  "creating secret \"error\": error 500 creating secret",
+		},
+		{
+			name:     "replication failure",
+			id:       "unreplicated",
+			replicas: []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: ""}, {Region: "fail-west-1", KmsKeyID: ""}},
+			wantErr:  `replicating secret "unreplicated" to fail-west-1 failed: Replication Failed`,
+		},
+		{
+			name:     "happy path",
+			id:       "happy",
+			replicas: []replicaRegionConfig{{Region: "us-east-2", KmsKeyID: "alias/sunlight-replica"}},
+			wantErr:  "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			meta := meta
+			meta.ReplicaRegions = tc.replicas
+
+			err := createSeed(t.Context(), testSM, tc.id, meta, make([]byte, seedLen))
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Errorf("createSeed(%q) = nil, but want error %q", tc.id, tc.wantErr)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("createSeed(%q) = %#v, but want error %q", tc.id, err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("createSeed(%q) = %#v, but want no error", tc.id, err)
+			}
+
+			testSM.mu.Lock()
+			req := testSM.created[tc.id]
+			testSM.mu.Unlock()
+
+			if aws.ToString(req.KmsKeyId) != "alias/sunlight" || aws.ToString(req.Description) != "Sunlight log seed" {
+				t.Errorf("createSeed(%q) created secret with key %q and description %q", tc.id, aws.ToString(req.KmsKeyId), aws.ToString(req.Description))
+			}
+
+			if len(req.AddReplicaRegions) != 1 || aws.ToString(req.AddReplicaRegions[0].KmsKeyId) != "alias/sunlight-replica" {
+				t.Errorf("createSeed(%q) created secret with replicas %#v", tc.id, req.AddReplicaRegions)
+			}
+
+			gotTags := make(map[string]string)
+			for _, tag := range req.Tags {
+				gotTags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+
+			wantTags := map[string]string{"team": "ct", logNameTag: "test.tld/shard1", logIDTag: "Z9tgKJw12Wg0PqrD3v8686Yjg5nAzA41TR/inpoM9T0="}
+			if !maps.Equal(gotTags, wantTags) {
+				t.Errorf("createSeed(%q) created secret with tags %v, but want %v", tc.id, gotTags, wantTags)
+			}
+		})
+	}
+}
+
+func TestPutSeed(t *testing.T) {
+	t.Parallel()
+
+	testSM := &fakeSecretsManager{} //nolint:exhaustruct
 
 	for _, tc := range []struct {
 		name    string
@@ -137,29 +813,47 @@ func TestCreateSeed(t *testing.T) {
 		{
 			name:    "error",
 			id:      "error",
-			wantErr: "creating secret \"error\": error 500 creating secret",
+			wantErr: "putting value of secret \"error\": error 500 putting value of secret",
 		},
 		{
 			name:    "happy path",
-			id:      "happy",
+			id:      "terraform",
 			wantErr: "",
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := createSeed(t.Context(), testSM, tc.id)
+			err := putSeed(t.Context(), testSM, tc.id, "", newSeed())
 			if tc.wantErr != "" {
 				if err == nil {
-					t.Errorf("createSeed(%q) = %#v, but want error %q", tc.id, got, tc.wantErr)
+					t.Errorf("putSeed(%q) = nil, but want error %q", tc.id, tc.wantErr)
 				} else if !strings.Contains(err.Error(), tc.wantErr) {
-					t.Errorf("createSeed(%q) = %#v, but want error %q", tc.id, err, tc.wantErr)
+					t.Errorf("putSeed(%q) = %#v, but want error %q", tc.id, err, tc.wantErr)
 				}
 			} else {
 				if err != nil {
-					t.Errorf("createSeed(%q) = %#v, but want no error", tc.id, err)
+					t.Errorf("putSeed(%q) = %#v, but want no error", tc.id, err)
 				}
 			}
 		})
 	}
 }
+
+func TestSeedRequestToken(t *testing.T) {
+	t.Parallel()
+
+	// Secrets Manager requires tokens of 32 to 64 characters.
+	token := seedRequestToken("sunlight/staging/shard1/seed")
+	if len(token) != 36 || token[14] != '8' {
+		t.Errorf("seedRequestToken() = %q, but want a version 8 UUID", token)
+	}
+
+	if token != seedRequestToken("sunlight/staging/shard1/seed") {
+		t.Errorf("seedRequestToken() is not deterministic")
+	}
+
+	if token == seedRequestToken("sunlight/staging/shard2/seed") {
+		t.Errorf("seedRequestToken() = %q for two different secrets", token)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// checkMinSeedLength returns an error if MinSeedLength would allow seeds
+// shorter than the 32 bytes Sunlight requires.
+func (smc secretManagerConfig) checkMinSeedLength() error {
+	if smc.MinSeedLength != 0 && smc.MinSeedLength < seedLen {
+		return fmt.Errorf("minseedlength %d is less than %d bytes", smc.MinSeedLength, seedLen)
+	}
+
+	return nil
+}
+
+// checkSeed returns an error if seed is not fit to be written. It must be
+// exactly seedLen bytes long, or at least MinSeedLength bytes long if that is
+// set, and it must not consist of a single repeated byte, such as all zeros,
+// which is what a seed left over from a botched migration or a placeholder
+// usually looks like.
+func (smc secretManagerConfig) checkSeed(seed []byte) error {
+	if smc.MinSeedLength == 0 && len(seed) != seedLen {
+		return fmt.Errorf("seed is %d bytes long, but must be exactly %d bytes", len(seed), seedLen)
+	}
+
+	if smc.MinSeedLength != 0 && len(seed) < smc.MinSeedLength {
+		return fmt.Errorf("seed is %d bytes long, but must be at least %d bytes", len(seed), smc.MinSeedLength)
+	}
+
+	if bytes.Count(seed, seed[:1]) == len(seed) {
+		return fmt.Errorf("seed consists of the byte 0x%02x repeated %d times", seed[0], len(seed))
+	}
+
+	return nil
+}
+
+// seedSet remembers the seeds of the logs seen so far in a run, by their
+// SHA-256 hash, so that two logs never share a key.
+type seedSet map[[sha256.Size]byte]string
+
+// add remembers the seed of the log with the given name. It returns an error if
+// another log has the same seed.
+func (s seedSet) add(name string, seed []byte) error {
+	h := sha256.Sum256(seed)
+	if other, ok := s[h]; ok {
+		return fmt.Errorf("seed is identical to the seed of log %q", other)
+	}
+
+	s[h] = name
+
+	return nil
+}
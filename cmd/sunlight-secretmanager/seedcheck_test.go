@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCheckMinSeedLength(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name          string
+		minSeedLength int
+		wantErr       bool
+	}{
+		{name: "unset", minSeedLength: 0, wantErr: false},
+		{name: "32", minSeedLength: 32, wantErr: false},
+		{name: "64", minSeedLength: 64, wantErr: false},
+		{name: "16", minSeedLength: 16, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := secretManagerConfig{MinSeedLength: tc.minSeedLength}.checkMinSeedLength() //nolint:exhaustruct
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkMinSeedLength() = %v, but want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSeed(t *testing.T) {
+	t.Parallel()
+
+	random := make([]byte, 64)
+	_, _ = rand.Read(random)
+
+	for _, tc := range []struct {
+		name          string
+		seed          []byte
+		minSeedLength int
+		wantErr       string
+	}{
+		{
+			name:    "random",
+			seed:    random[:seedLen],
+			wantErr: "",
+		},
+		{
+			name:    "empty",
+			seed:    nil,
+			wantErr: "seed is 0 bytes long, but must be exactly 32 bytes",
+		},
+		{
+			name:    "short",
+			seed:    []byte("hello world"),
+			wantErr: "seed is 11 bytes long, but must be exactly 32 bytes",
+		},
+		{
+			name:    "long",
+			seed:    random,
+			wantErr: "seed is 64 bytes long, but must be exactly 32 bytes",
+		},
+		{
+			name:    "all zeros",
+			seed:    make([]byte, seedLen),
+			wantErr: "seed consists of the byte 0x00 repeated 32 times",
+		},
+		{
+			name:    "repeated byte",
+			seed:    bytes.Repeat([]byte{0xff}, seedLen),
+			wantErr: "seed consists of the byte 0xff repeated 32 times",
+		},
+		{
+			name:          "long with minimum",
+			seed:          random,
+			minSeedLength: seedLen,
+			wantErr:       "",
+		},
+		{
+			name:          "short with minimum",
+			seed:          random[:40],
+			minSeedLength: 48,
+			wantErr:       "seed is 40 bytes long, but must be at least 48 bytes",
+		},
+		{
+			name:          "empty with minimum",
+			seed:          nil,
+			minSeedLength: seedLen,
+			wantErr:       "seed is 0 bytes long, but must be at least 32 bytes",
+		},
+		{
+			name:          "repeated byte with minimum",
+			seed:          bytes.Repeat([]byte{0x41}, 48),
+			minSeedLength: seedLen,
+			wantErr:       "seed consists of the byte 0x41 repeated 48 times",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := secretManagerConfig{MinSeedLength: tc.minSeedLength}.checkSeed(tc.seed) //nolint:exhaustruct
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("checkSeed() = %q, but want success", err)
+			} else if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Errorf("checkSeed() = %v, but want error %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeedSet(t *testing.T) {
+	t.Parallel()
+
+	seeds := make(seedSet)
+
+	err := seeds.add("test.tld/shard1", []byte("seed one"))
+	if err != nil {
+		t.Fatalf("add(shard1) = %q, but want success", err)
+	}
+
+	err = seeds.add("test.tld/shard2", []byte("seed two"))
+	if err != nil {
+		t.Fatalf("add(shard2) = %q, but want success", err)
+	}
+
+	err = seeds.add("test.tld/shard3", []byte("seed one"))
+	if err == nil || err.Error() != `seed is identical to the seed of log "test.tld/shard1"` {
+		t.Errorf("add(shard3) = %v, but want an error naming shard1", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sunlightConfig models the whole of Sunlight's Config, unlike config. The
+// validate command decodes it strictly, so that typos in fields which we don't
+// otherwise use are caught before Sunlight itself starts.
+type sunlightConfig struct {
+	Listen      []string
+	ACME        sunlightACMEConfig
+	Checkpoints string
+	DynamoDB    sunlightDynamoDBConfig
+	// SecretManager is our own addition to Sunlight's Config.
+	SecretManager secretManagerConfig
+	Logs          []sunlightLogConfig
+}
+
+// sunlightACMEConfig models Sunlight's ACME configuration.
+type sunlightACMEConfig struct {
+	Email     string
+	Host      string
+	Cache     string
+	Directory string
+}
+
+// sunlightDynamoDBConfig models Sunlight's DynamoDB lock backend configuration.
+type sunlightDynamoDBConfig struct {
+	Region   string
+	Table    string
+	Endpoint string
+}
+
+// sunlightLogConfig models the whole of Sunlight's LogConfig, as well as our
+// own additions to it. The fields we use are those of the embedded logConfig.
+type sunlightLogConfig struct {
+	logConfig `yaml:",inline"`
+
+	HTTPPrefix  string
+	Roots       string
+	Cache       string
+	PoolSize    int
+	S3Region    string
+	S3Bucket    string
+	S3Endpoint  string
+	S3KeyPrefix string
+}
+
+// validateCommand checks the whole config file for problems, and reports all
+// of them at once. It doesn't talk to Secrets Manager.
+func validateCommand(args []string) {
+	flagset := flag.NewFlagSet("sunlight-secretmanager validate", flag.ContinueOnError)
+	configFlag := flagset.String("config", "", "Path to YAML config file")
+
+	err := flagset.Parse(args)
+	if err != nil {
+		log.Fatalf("Error parsing flags: %s", err)
+	}
+
+	problems := validateConfig(*configFlag)
+	for _, problem := range problems {
+		log.Printf("Problem: %s", problem)
+	}
+
+	if len(problems) > 0 {
+		log.Fatalf("Found %d problems in config file %q", len(problems), *configFlag)
+	}
+
+	log.Printf("Config file %q is valid", *configFlag)
+}
+
+// validateConfig strictly parses the config file and returns every problem it
+// finds in it, rather than stopping at the first one.
+func validateConfig(configFile string) []error {
+	yml, err := os.ReadFile(configFile)
+	if err != nil {
+		return []error{fmt.Errorf("reading config file %q: %w", configFile, err)}
+	}
+
+	var problems []error
+
+	var conf sunlightConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(yml))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(&conf)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		// A TypeError means that decoding carried on past the bad fields, so
+		// we can report them and then check the rest of the config.
+		for _, msg := range typeErr.Errors {
+			msg, _, _ = strings.Cut(msg, " in type ")
+			problems = append(problems, errors.New(msg))
+		}
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		return []error{fmt.Errorf("parsing config file %q: %w", configFile, err)}
+	}
+
+	if len(conf.Logs) == 0 {
+		return append(problems, errors.New("no logs found"))
+	}
+
+	naming, smProblems := conf.SecretManager.check()
+	problems = append(problems, smProblems...)
+
+	for i, lc := range conf.Logs {
+		problems = append(problems, validateLogConfig(lc.logConfig)...)
+
+		err = conf.SecretManager.checkRole(lc.logConfig)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("log %q: %w", lc.Name, err))
+		}
+
+		id, err := lc.resolveSecretID(naming)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("log %q: %w", lc.Name, err))
+		}
+
+		conf.Logs[i].SecretID = id
+	}
+
+	problems = append(problems, validateUnique(conf.Logs, "name", func(lc logConfig) string { return lc.Name })...)
+	problems = append(problems, validateUnique(conf.Logs, "shortname", func(lc logConfig) string { return lc.ShortName })...)
+	problems = append(problems, validateOutputPaths(conf.Logs)...)
+	problems = append(problems, validateUnique(conf.Logs, "secret ID", func(lc logConfig) string { return lc.SecretID })...)
+	problems = append(problems, validateShardWindows(conf.Logs)...)
+
+	return problems
+}
+
+// validateLogConfig returns the problems with a single log's config.
+func validateLogConfig(lc logConfig) []error {
+	var problems []error
+
+	for _, required := range []struct {
+		field string
+		value string
+	}{
+		{field: "name", value: lc.Name},
+		{field: "shortname", value: lc.ShortName},
+		{field: "inception", value: lc.Inception},
+		{field: "secret", value: lc.Secret},
+	} {
+		if required.value == "" {
+			problems = append(problems, fmt.Errorf("log %q: missing %s", lc.Name, required.field))
+		}
+	}
+
+	if lc.Inception != "" {
+		_, err := parseInception(lc.Inception)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("log %q: %w", lc.Name, err))
+		}
+	}
+
+	err := lc.checkLogIDFormat()
+	if err != nil {
+		problems = append(problems, fmt.Errorf("log %q: %w", lc.Name, err))
+	}
+
+	if lc.NotAfterStart == "" && lc.NotAfterLimit == "" {
+		return problems
+	}
+
+	start, startErr := time.Parse(time.RFC3339, lc.NotAfterStart)
+	if startErr != nil {
+		problems = append(problems, fmt.Errorf("log %q: notafterstart %q is not an RFC 3339 timestamp", lc.Name, lc.NotAfterStart))
+	}
+
+	limit, limitErr := time.Parse(time.RFC3339, lc.NotAfterLimit)
+	if limitErr != nil {
+		problems = append(problems, fmt.Errorf("log %q: notafterlimit %q is not an RFC 3339 timestamp", lc.Name, lc.NotAfterLimit))
+	}
+
+	if startErr == nil && limitErr == nil && !start.Before(limit) {
+		problems = append(problems, fmt.Errorf("log %q: notafterstart %s is not before notafterlimit %s", lc.Name, lc.NotAfterStart, lc.NotAfterLimit))
+	}
+
+	return problems
+}
+
+// validateUnique returns a problem for every pair of logs which share the same
+// (non-empty) value of the given field.
+func validateUnique(logs []sunlightLogConfig, field string, value func(logConfig) string) []error {
+	var problems []error
+
+	seen := make(map[string]string)
+	for _, lc := range logs {
+		v := value(lc.logConfig)
+		if v == "" || v == "." {
+			continue
+		}
+
+		if other, ok := seen[v]; ok {
+			problems = append(problems, fmt.Errorf("logs %q and %q have the same %s %q", other, lc.Name, field, v))
+		} else {
+			seen[v] = lc.Name
+		}
+	}
+
+	return problems
+}
+
+// validateOutputPaths returns the problems which checkOutputPaths finds with
+// the files the logs are written to.
+func validateOutputPaths(logs []sunlightLogConfig) []error {
+	configs := make([]logConfig, 0, len(logs))
+	for _, lc := range logs {
+		configs = append(configs, lc.logConfig)
+	}
+
+	return checkOutputPaths(configs)
+}
+
+// validateShardWindows returns a problem for every pair of shards of the same
+// log whose NotAfterStart/NotAfterLimit windows overlap. Shards of the same log
+// are those whose names differ only in the last path element, such as
+// "example.com/2025h1" and "example.com/2025h2".
+func validateShardWindows(logs []sunlightLogConfig) []error {
+	type window struct {
+		name         string
+		start, limit time.Time
+	}
+
+	var problems []error
+
+	families := make(map[string][]window)
+	for _, lc := range logs {
+		start, err := time.Parse(time.RFC3339, lc.NotAfterStart)
+		if err != nil {
+			continue
+		}
+
+		limit, err := time.Parse(time.RFC3339, lc.NotAfterLimit)
+		if err != nil || !start.Before(limit) {
+			continue
+		}
+
+		slash := strings.LastIndex(lc.Name, "/")
+		if slash < 0 {
+			continue
+		}
+
+		family := lc.Name[:slash]
+		for _, other := range families[family] {
+			if start.Before(other.limit) && other.start.Before(limit) {
+				problems = append(problems, fmt.Errorf("logs %q and %q have overlapping notafterstart/notafterlimit windows", other.name, lc.Name))
+			}
+		}
+
+		families[family] = append(families[family], window{name: lc.Name, start: start, limit: limit})
+	}
+
+	return problems
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "invalid path",
+			input: "",
+			want:  []string{`reading config file "testdata": read testdata: is a directory`},
+		},
+		{
+			name:  "invalid yaml",
+			input: "invalid.yaml",
+			want:  []string{"line 1: cannot unmarshal !!str `asdfqwer$` into main.sunlightConfig", "no logs found"},
+		},
+		{
+			name:  "empty",
+			input: "empty.yaml",
+			want:  []string{"no logs found"},
+		},
+		{
+			name:  "no logs",
+			input: "nologs.yaml",
+			want:  []string{"no logs found"},
+		},
+		{
+			name:  "problems",
+			input: "problems.yaml",
+			want: []string{
+				"line 2: field checkpionts not found",
+				"line 31: field submisionprefix not found",
+				"inceptionwindowdays is only used by the inception-window seedcreation policy",
+				`log "test.tld/shard2": inception "08/07/2024" is not a YYYY-MM-DD date`,
+				`log "test.tld/shard3": invalid log ID "not-a-log-id"`,
+				`log "test.tld/shard3": notafterstart 2026-07-01T00:00:00Z is not before notafterlimit 2026-01-01T00:00:00Z`,
+				`log "other.tld/shard1": missing inception`,
+				`log "other.tld/shard1": notafterlimit "2025-07-01" is not an RFC 3339 timestamp`,
+				`logs "test.tld/shard1" and "test.tld/shard2" have the same shortname "shard1"`,
+				`the seed of log "test.tld/shard1" and the seed of log "test.tld/shard2" would both be written to "/path/to/shard1.seed"`,
+				`the public key of log "test.tld/shard1" and the public key of log "test.tld/shard2" would both be written to "/path/to/shard1.pem"`,
+				`the seed of log "test.tld/shard3" and the public key of log "other.tld/shard1" would both be written to "/path/to/shard3.seed"`,
+				`logs "test.tld/shard1" and "test.tld/shard2" have the same secret ID "shard1.seed"`,
+				`logs "test.tld/shard1" and "test.tld/shard2" have overlapping notafterstart/notafterlimit windows`,
+			},
+		},
+		{
+			name:  "happy path",
+			input: "happy.yaml",
+			want:  nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := validateConfig(filepath.Join("testdata", tc.input))
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("validateConfig() = %q, but want %q", got, tc.want)
+			}
+
+			for i := range got {
+				if got[i].Error() != tc.want[i] {
+					t.Errorf("validateConfig() problem %d = %q, but want %q", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
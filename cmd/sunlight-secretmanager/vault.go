@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultVaultMount is the path at which the KV v2 secrets engine is
+	// mounted by default.
+	defaultVaultMount = "secret"
+	// defaultVaultAuthMount is the path at which the AppRole auth method is
+	// mounted by default.
+	defaultVaultAuthMount = "approle"
+	// vaultSeedField is the field of a KV secret which holds the seed.
+	vaultSeedField = "seed"
+	// vaultPinnedKey is the custom metadata key with which we record the
+	// version of a secret that holds the log's seed, the first time we use
+	// it, like pinnedStage does for Secrets Manager.
+	vaultPinnedKey = "sunlight-pinned-version"
+	// vaultRequestTimeout is how long we wait for each response from Vault.
+	vaultRequestTimeout = 30 * time.Second
+	// maxVaultResponse is the largest response from Vault we read.
+	maxVaultResponse = 1 << 20
+)
+
+// vaultConfig configures the Vault backend.
+type vaultConfig struct {
+	// Address is the URL of the Vault server, such as
+	// "https://vault.example.com:8200". It defaults to the VAULT_ADDR
+	// environment variable.
+	Address string
+	// Mount is the path at which the KV v2 secrets engine holding the seeds
+	// is mounted. It defaults to "secret".
+	Mount string
+	// Namespace is the Vault Enterprise namespace of the secrets. It is
+	// optional.
+	Namespace string
+	// RoleID is the role ID with which to log in with AppRole. If it is not
+	// set, the token in the VAULT_TOKEN environment variable is used
+	// instead.
+	RoleID string
+	// SecretIDFile is the path to a file holding the AppRole secret ID. If it
+	// is not set, the VAULT_SECRET_ID environment variable is used instead.
+	SecretIDFile string
+	// AuthMount is the path at which the AppRole auth method is mounted. It
+	// defaults to "approle".
+	AuthMount string
+}
+
+// checkVault returns an error if the Vault settings are unusable. They are
+// only checked if the Vault backend is selected.
+func (smc secretManagerConfig) checkVault() error {
+	if smc.Backend != backendVault {
+		return nil
+	}
+
+	if smc.Encoding == encodingBinary {
+		return fmt.Errorf("encoding %s can't be used with the %s backend: must be %s or %s", encodingBinary, backendVault, encodingBase64, encodingHex)
+	}
+
+	if smc.Vault.Address != "" {
+		u, err := url.Parse(smc.Vault.Address)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("vault address %q is not an absolute URL", smc.Vault.Address)
+		}
+	}
+
+	if smc.Vault.SecretIDFile != "" && smc.Vault.RoleID == "" {
+		return fmt.Errorf("vault secretidfile %q is only used with roleid", smc.Vault.SecretIDFile)
+	}
+
+	return nil
+}
+
+// vaultBackend keeps seeds in a Vault KV v2 secrets engine. Every log's
+// secret is accessed with the same token.
+type vaultBackend struct {
+	kv *vaultStore
+}
+
+// newVaultBackend returns a backend which logs in to Vault as configured by
+// smc, with environment variables filling in what smc leaves unset.
+func newVaultBackend(ctx context.Context, smc secretManagerConfig) (*vaultBackend, error) {
+	httpClient := &http.Client{Timeout: vaultRequestTimeout} //nolint:exhaustruct
+
+	kv, err := newVaultStore(ctx, smc, os.Getenv, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vaultBackend{kv: kv}, nil
+}
+
+func (b *vaultBackend) store(logConfig) (seedStore, error) {
+	return b.kv, nil
+}
+
+// fetchAll fetches the seeds of the given logs one at a time, retrying
+// transient errors.
+func (b *vaultBackend) fetchAll(ctx context.Context, logs []logConfig) map[string]seedFetch {
+	fetched := make(map[string]seedFetch, len(logs))
+
+	for _, lc := range logs {
+		var version seedVersion
+		seed, err := retryTransient(ctx, transientRetryDelays, func() ([]byte, error) {
+			seed, v, err := b.kv.Get(ctx, lc.SecretID)
+			version = v
+
+			return seed, err
+		})
+
+		fetched[lc.SecretID] = seedFetch{Seed: seed, Version: version, Err: err}
+	}
+
+	return fetched
+}
+
+// vaultStore holds the seeds of logs in a Vault KV v2 secrets engine, talking
+// to Vault's HTTP API directly. Each seed is kept in the vaultSeedField field
+// of its secret, encoded as base64 or hex.
+type vaultStore struct {
+	address    string
+	mount      string
+	namespace  string
+	token      string
+	encoding   string
+	httpClient *http.Client
+}
+
+// newVaultStore returns a store for the Vault server configured by smc. It
+// logs in with AppRole if smc configures a role ID, and otherwise uses the
+// token in VAULT_TOKEN. Environment variables are looked up with getenv.
+func newVaultStore(ctx context.Context, smc secretManagerConfig, getenv func(string) string, httpClient *http.Client) (*vaultStore, error) {
+	vc := smc.Vault
+
+	address := cmp.Or(vc.Address, getenv("VAULT_ADDR"))
+	if address == "" {
+		return nil, errors.New("no Vault address: set vault address in the config or VAULT_ADDR")
+	}
+
+	s := &vaultStore{
+		address:    strings.TrimSuffix(address, "/"),
+		mount:      strings.Trim(cmp.Or(vc.Mount, defaultVaultMount), "/"),
+		namespace:  vc.Namespace,
+		token:      "",
+		encoding:   cmp.Or(smc.Encoding, encodingBase64),
+		httpClient: httpClient,
+	}
+
+	if vc.RoleID == "" {
+		s.token = getenv("VAULT_TOKEN")
+		if s.token == "" {
+			return nil, errors.New("no Vault token: set VAULT_TOKEN, or vault roleid in the config")
+		}
+
+		return s, nil
+	}
+
+	secretID := getenv("VAULT_SECRET_ID")
+	if vc.SecretIDFile != "" {
+		b, err := os.ReadFile(vc.SecretIDFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading Vault secret ID: %w", err)
+		}
+
+		secretID = strings.TrimSpace(string(b))
+	}
+
+	if secretID == "" {
+		return nil, errors.New("no Vault secret ID: set vault secretidfile in the config or VAULT_SECRET_ID")
+	}
+
+	err := s.login(ctx, strings.Trim(cmp.Or(vc.AuthMount, defaultVaultAuthMount), "/"), vc.RoleID, secretID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// vaultVersionMetadata is the metadata of a single version of a KV v2 secret.
+type vaultVersionMetadata struct {
+	Version      int    `json:"version"`
+	CreatedTime  string `json:"created_time"`  //nolint:tagliatelle
+	DeletionTime string `json:"deletion_time"` //nolint:tagliatelle
+	Destroyed    bool   `json:"destroyed"`
+}
+
+// vaultSecretData is the response to reading a KV v2 secret.
+type vaultSecretData struct {
+	Data     map[string]any       `json:"data"`
+	Metadata vaultVersionMetadata `json:"metadata"`
+}
+
+// vaultSecretMetadata is the response to reading the metadata of a KV v2
+// secret.
+type vaultSecretMetadata struct {
+	CurrentVersion     int               `json:"current_version"`      //nolint:tagliatelle
+	CASRequired        bool              `json:"cas_required"`         //nolint:tagliatelle
+	DeleteVersionAfter string            `json:"delete_version_after"` //nolint:tagliatelle
+	CustomMetadata     map[string]string `json:"custom_metadata"`      //nolint:tagliatelle
+}
+
+// login logs in with the AppRole auth method mounted at mount, and uses the
+// token it returns from then on.
+func (s *vaultStore) login(ctx context.Context, mount, roleID, secretID string) error {
+	var res struct {
+		Auth struct {
+			ClientToken string `json:"client_token"` //nolint:tagliatelle
+		} `json:"auth"`
+	}
+
+	req := map[string]string{"role_id": roleID, "secret_id": secretID}
+
+	err := s.do(ctx, http.MethodPost, "auth/"+mount+"/login", req, &res)
+	if err != nil {
+		return fmt.Errorf("logging in to Vault with AppRole role ID %q: %w", roleID, err)
+	}
+
+	if res.Auth.ClientToken == "" {
+		return fmt.Errorf("logging in to Vault with AppRole role ID %q returned no token", roleID)
+	}
+
+	s.token = res.Auth.ClientToken
+
+	return nil
+}
+
+// Get reads the latest version of the secret with the given ID. A version
+// which was deleted or destroyed is an error, rather than a missing secret,
+// since it means that the log's seed has been lost, not that the log is new.
+func (s *vaultStore) Get(ctx context.Context, id string) ([]byte, seedVersion, error) {
+	var res struct {
+		Data vaultSecretData `json:"data"`
+	}
+
+	version := seedVersion{ID: "", Source: s.address, Replica: false}
+
+	err := s.do(ctx, http.MethodGet, s.path("data", id), nil, &res)
+	if errors.Is(err, errSecretNotFound) && res.Data.Metadata.Version != 0 {
+		meta := res.Data.Metadata
+		if meta.Destroyed {
+			return nil, version, fmt.Errorf("version %d of secret %q was destroyed, and the log's seed with it", meta.Version, id)
+		}
+
+		return nil, version, fmt.Errorf("version %d of secret %q was deleted at %s; undelete it with \"vault kv undelete\" rather than creating a new seed",
+			meta.Version, id, meta.DeletionTime)
+	} else if err != nil {
+		return nil, version, fmt.Errorf("retrieving secret %q: %w", id, err)
+	}
+
+	version.ID = strconv.Itoa(res.Data.Metadata.Version)
+
+	str, ok := res.Data.Data[vaultSeedField].(string)
+	if !ok {
+		return nil, version, fmt.Errorf("secret %q has no %q field holding a string", id, vaultSeedField)
+	}
+
+	seed, err := s.decode(str)
+	if err != nil {
+		return nil, version, fmt.Errorf("decoding field %q of secret %q as %s: %w", vaultSeedField, id, s.encoding, err)
+	}
+
+	return seed, version, nil
+}
+
+// CreateIfAbsent writes seed as the first version of the secret with the
+// given ID, with check-and-set so that Vault rejects the write if any version
+// exists already, such as one written by another host in the meantime. Either
+// way, it returns the seed which is actually stored, as read back from Vault.
+func (s *vaultStore) CreateIfAbsent(ctx context.Context, id string, seed []byte) ([]byte, seedVersion, error) {
+	req := map[string]any{
+		"options": map[string]int{"cas": 0},
+		"data":    map[string]string{vaultSeedField: s.encode(seed)},
+	}
+
+	err := s.do(ctx, http.MethodPost, s.path("data", id), req, nil)
+	if errors.Is(err, errSecretExists) {
+		log.Printf("Secret %q was initialized concurrently by another host, using its seed: %v", id, err)
+	} else if err != nil {
+		return nil, seedVersion{}, fmt.Errorf("writing secret %q: %w", id, err) //nolint:exhaustruct
+	}
+
+	stored, version, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, version, fmt.Errorf("re-fetching secret %q: %w", id, err)
+	}
+
+	return stored, version, nil
+}
+
+// CheckPin makes sure that the seed in the secret with the given ID has never
+// changed, with checkPin.
+func (s *vaultStore) CheckPin(ctx context.Context, id string, version seedVersion) error {
+	_, _, err := s.checkPin(ctx, id, version)
+
+	return err
+}
+
+// Pin checks the version with checkPin, and if no version is recorded yet,
+// records the current version in the secret's custom metadata under
+// vaultPinnedKey.
+func (s *vaultStore) Pin(ctx context.Context, id string, version seedVersion) error {
+	meta, unpinned, err := s.checkPin(ctx, id, version)
+	if err != nil || unpinned == "" {
+		return err
+	}
+
+	// Writing custom_metadata replaces all of it, so we keep what's there.
+	custom := maps.Clone(meta.CustomMetadata)
+	if custom == nil {
+		custom = make(map[string]string)
+	}
+
+	custom[vaultPinnedKey] = unpinned
+
+	err = s.do(ctx, http.MethodPost, s.path("metadata", id), map[string]any{"custom_metadata": custom}, nil)
+	if err != nil {
+		return fmt.Errorf("pinning version %s of secret %q: %w", unpinned, id, err)
+	}
+
+	log.Printf("Pinned version %s of secret %q with custom metadata %s", unpinned, id, vaultPinnedKey)
+
+	return nil
+}
+
+// checkPin fails if the version which held the seed is not the secret's
+// current version, or if the current version is not the one recorded in the
+// secret's custom metadata under vaultPinnedKey. If no version is recorded
+// yet, the current version must be the secret's first version, and it is
+// returned along with the secret's metadata so that Pin can record it.
+// Otherwise, the empty string is returned.
+func (s *vaultStore) checkPin(ctx context.Context, id string, version seedVersion) (vaultSecretMetadata, string, error) {
+	meta, err := s.metadata(ctx, id)
+	if err != nil {
+		return meta, "", err
+	}
+
+	current := strconv.Itoa(meta.CurrentVersion)
+	if version.ID != current {
+		return meta, "", fmt.Errorf("secret %q has current version %s, but the seed was read from version %s", id, current, version.ID)
+	}
+
+	pinned := meta.CustomMetadata[vaultPinnedKey]
+	if pinned != "" {
+		if pinned != current {
+			return meta, "", fmt.Errorf("secret %q has current version %s, but its pinned version is %s: the seed has changed since it was first used",
+				id, current, pinned)
+		}
+
+		return meta, "", nil
+	}
+
+	if meta.CurrentVersion != 1 {
+		return meta, "", fmt.Errorf("secret %q has current version %s, but its first version is 1: the seed has changed since it was created", id, current)
+	}
+
+	return meta, current, nil
+}
+
+// CheckSafety fails if the secret with the given ID is set to delete its
+// versions automatically. It also warns if the secret doesn't require
+// check-and-set, which would let its seed be overwritten by mistake.
+func (s *vaultStore) CheckSafety(ctx context.Context, id string, _ seedVersion) error {
+	meta, err := s.metadata(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if meta.DeleteVersionAfter != "" && meta.DeleteVersionAfter != "0s" {
+		return fmt.Errorf("secret %q deletes its versions after %s, but a log's seed must never be deleted", id, meta.DeleteVersionAfter)
+	}
+
+	if !meta.CASRequired {
+		log.Printf("Secret %q doesn't require check-and-set, so its seed could be overwritten by mistake", id)
+	}
+
+	return nil
+}
+
+// metadata reads the metadata of the secret with the given ID.
+func (s *vaultStore) metadata(ctx context.Context, id string) (vaultSecretMetadata, error) {
+	var res struct {
+		Data vaultSecretMetadata `json:"data"`
+	}
+
+	err := s.do(ctx, http.MethodGet, s.path("metadata", id), nil, &res)
+	if err != nil {
+		return vaultSecretMetadata{}, fmt.Errorf("reading metadata of secret %q: %w", id, err) //nolint:exhaustruct
+	}
+
+	return res.Data, nil
+}
+
+// path returns the API path of the given kind, "data" or "metadata", of the
+// secret with the given ID.
+func (s *vaultStore) path(kind, id string) string {
+	segments := strings.Split(id, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return s.mount + "/" + kind + "/" + strings.Join(segments, "/")
+}
+
+// encode encodes seed for storing in a secret.
+func (s *vaultStore) encode(seed []byte) string {
+	if s.encoding == encodingHex {
+		return hex.EncodeToString(seed)
+	}
+
+	return base64.StdEncoding.EncodeToString(seed)
+}
+
+// decode decodes a seed stored in a secret.
+func (s *vaultStore) decode(str string) ([]byte, error) {
+	if s.encoding == encodingHex {
+		return hex.DecodeString(str) //nolint:wrapcheck
+	}
+
+	return base64.StdEncoding.DecodeString(str) //nolint:wrapcheck
+}
+
+// do sends a request to the Vault API at the given path, with body encoded as
+// JSON unless it is nil, and decodes the response into res unless it is nil.
+// Errors wrap errSecretNotFound for 404 responses, whose bodies are decoded
+// too, errSecretExists for check-and-set failures, and errTransient for
+// network failures, throttling and server errors, including a sealed Vault.
+func (s *vaultStore) do(ctx context.Context, method, path string, body, res any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.address+"/v1/"+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if s.token != "" {
+		req.Header.Set("X-Vault-Token", s.token)
+	}
+
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errTransient, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxVaultResponse))
+	if err != nil {
+		return fmt.Errorf("%w: reading response: %w", errTransient, err)
+	}
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		if res != nil && len(data) != 0 {
+			err = json.Unmarshal(data, res)
+			if err != nil {
+				return fmt.Errorf("decoding response to %s %s: %w", method, path, err)
+			}
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			return nil
+		}
+	}
+
+	var errs struct {
+		Errors []string `json:"errors"`
+	}
+	_ = json.Unmarshal(data, &errs)
+
+	err = fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.Join(errs.Errors, "; "))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %w", errSecretNotFound, err)
+	case resp.StatusCode == http.StatusBadRequest && strings.Contains(err.Error(), "check-and-set"):
+		return fmt.Errorf("%w: %w", errSecretExists, err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w", errTransient, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeVaultToken    = "hvs.test-token"
+	fakeVaultRoleID   = "sunlight-role"
+	fakeVaultSecretID = "sunlight-secret"
+)
+
+// fakeVaultSecret is a KV v2 secret kept by fakeVault.
+type fakeVaultSecret struct {
+	// versions are the seed field of each version, oldest first.
+	versions []string
+	// deleted is set if the latest version has been deleted.
+	deleted     bool
+	custom      map[string]string
+	deleteAfter string
+}
+
+// fakeVault is a stand-in for the parts of the Vault HTTP API which vaultStore
+// uses: AppRole login, and reading and writing the data and metadata of the
+// secrets in a KV v2 engine mounted at "secret". The secret "sealed" always
+// fails as if Vault were sealed.
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]*fakeVaultSecret
+}
+
+// newFakeVault returns a fakeVault holding canned secrets, and an httptest
+// server which serves it.
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	hello := base64.StdEncoding.EncodeToString([]byte("hello world"))
+
+	fv := &fakeVault{ //nolint:exhaustruct
+		secrets: map[string]*fakeVaultSecret{
+			"real":      {versions: []string{hello}, deleted: false, custom: map[string]string{"owner": "ct"}, deleteAfter: ""},
+			"pinned":    {versions: []string{hello}, deleted: false, custom: map[string]string{vaultPinnedKey: "1"}, deleteAfter: ""},
+			"rewritten": {versions: []string{hello, hello}, deleted: false, custom: nil, deleteAfter: ""},
+			"changed":   {versions: []string{hello, hello}, deleted: false, custom: map[string]string{vaultPinnedKey: "1"}, deleteAfter: ""},
+			"deleted":   {versions: []string{hello}, deleted: true, custom: nil, deleteAfter: ""},
+			"expiring":  {versions: []string{hello}, deleted: false, custom: nil, deleteAfter: "768h0m0s"},
+			"garbled":   {versions: []string{"not base64!"}, deleted: false, custom: nil, deleteAfter: ""},
+		},
+	}
+
+	server := httptest.NewServer(fv)
+	t.Cleanup(server.Close)
+
+	return fv, server
+}
+
+// secret returns a copy of the secret with the given ID.
+func (fv *fakeVault) secret(id string) fakeVaultSecret {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+
+	if fv.secrets[id] == nil {
+		return fakeVaultSecret{} //nolint:exhaustruct
+	}
+
+	return *fv.secrets[id]
+}
+
+// reply writes v as the JSON response body, with the given status code.
+func reply(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (fv *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/auth/approle/login" && r.Method == http.MethodPost {
+		var req struct {
+			RoleID   string `json:"role_id"`   //nolint:tagliatelle
+			SecretID string `json:"secret_id"` //nolint:tagliatelle
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		if req.RoleID != fakeVaultRoleID || req.SecretID != fakeVaultSecretID {
+			reply(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret ID"}})
+
+			return
+		}
+
+		reply(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": fakeVaultToken}})
+
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != fakeVaultToken {
+		reply(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+
+		return
+	}
+
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+
+	if id, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/"); ok {
+		fv.serveData(w, r, id)
+	} else if id, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/metadata/"); ok {
+		fv.serveMetadata(w, r, id)
+	} else {
+		reply(w, http.StatusNotFound, map[string]any{"errors": []string{"no handler for route"}})
+	}
+}
+
+func (fv *fakeVault) serveData(w http.ResponseWriter, r *http.Request, id string) {
+	if id == "sealed" {
+		reply(w, http.StatusServiceUnavailable, map[string]any{"errors": []string{"Vault is sealed"}})
+
+		return
+	}
+
+	secret := fv.secrets[id]
+
+	switch r.Method {
+	case http.MethodGet:
+		if secret == nil || len(secret.versions) == 0 {
+			reply(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+
+			return
+		}
+
+		version := len(secret.versions)
+		if secret.deleted {
+			reply(w, http.StatusNotFound, map[string]any{"data": map[string]any{
+				"data":     nil,
+				"metadata": map[string]any{"version": version, "deletion_time": "2025-01-02T03:04:05Z", "destroyed": false},
+			}})
+
+			return
+		}
+
+		reply(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"data":     map[string]any{vaultSeedField: secret.versions[version-1]},
+			"metadata": map[string]any{"version": version, "deletion_time": "", "destroyed": false},
+		}})
+	case http.MethodPost:
+		var req struct {
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+			Data map[string]string `json:"data"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		current := 0
+		if secret != nil {
+			current = len(secret.versions)
+		}
+
+		if req.Options.CAS == nil || *req.Options.CAS != current {
+			reply(w, http.StatusBadRequest, map[string]any{"errors": []string{"check-and-set parameter did not match the current version"}})
+
+			return
+		}
+
+		if secret == nil {
+			secret = &fakeVaultSecret{} //nolint:exhaustruct
+			fv.secrets[id] = secret
+		}
+
+		secret.versions = append(secret.versions, req.Data[vaultSeedField])
+
+		reply(w, http.StatusOK, map[string]any{"data": map[string]any{"version": len(secret.versions)}})
+	}
+}
+
+func (fv *fakeVault) serveMetadata(w http.ResponseWriter, r *http.Request, id string) {
+	secret := fv.secrets[id]
+	if secret == nil {
+		reply(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reply(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"current_version":      len(secret.versions),
+			"cas_required":         false,
+			"delete_version_after": cmp.Or(secret.deleteAfter, "0s"),
+			"custom_metadata":      secret.custom,
+		}})
+	case http.MethodPost:
+		var req struct {
+			CustomMetadata map[string]string `json:"custom_metadata"` //nolint:tagliatelle
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		secret.custom = req.CustomMetadata
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newTestVaultStore returns a vaultStore which uses server with a token.
+func newTestVaultStore(t *testing.T, server *httptest.Server) *vaultStore {
+	t.Helper()
+
+	smc := secretManagerConfig{Backend: backendVault, Vault: vaultConfig{Address: server.URL}} //nolint:exhaustruct
+	env := map[string]string{"VAULT_TOKEN": fakeVaultToken}
+
+	s, err := newVaultStore(t.Context(), smc, func(key string) string { return env[key] }, server.Client())
+	if err != nil {
+		t.Fatalf("newVaultStore() = %q, but want success", err)
+	}
+
+	return s
+}
+
+func TestCheckVault(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		smc     secretManagerConfig
+		wantErr string
+	}{
+		{
+			name:    "aws backend",
+			smc:     secretManagerConfig{Encoding: encodingBinary, Vault: vaultConfig{Address: "vault"}}, //nolint:exhaustruct
+			wantErr: "",
+		},
+		{
+			name:    "defaults",
+			smc:     secretManagerConfig{Backend: backendVault}, //nolint:exhaustruct
+			wantErr: "",
+		},
+		{
+			name:    "approle",
+			smc:     secretManagerConfig{Backend: backendVault, Encoding: encodingHex, Vault: vaultConfig{Address: "https://vault.example.com:8200", RoleID: "sunlight", SecretIDFile: "/run/secret-id"}}, //nolint:exhaustruct
+			wantErr: "",
+		},
+		{
+			name:    "binary encoding",
+			smc:     secretManagerConfig{Backend: backendVault, Encoding: encodingBinary}, //nolint:exhaustruct
+			wantErr: "encoding binary can't be used with the vault backend: must be base64 or hex",
+		},
+		{
+			name:    "relative address",
+			smc:     secretManagerConfig{Backend: backendVault, Vault: vaultConfig{Address: "vault.example.com"}}, //nolint:exhaustruct
+			wantErr: `vault address "vault.example.com" is not an absolute URL`,
+		},
+		{
+			name:    "secret ID without role",
+			smc:     secretManagerConfig{Backend: backendVault, Vault: vaultConfig{SecretIDFile: "/run/secret-id"}}, //nolint:exhaustruct
+			wantErr: `vault secretidfile "/run/secret-id" is only used with roleid`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.smc.checkVault()
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("checkVault() = %q, but want success", err)
+			} else if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Errorf("checkVault() = %v, but want error %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewVaultStore(t *testing.T) {
+	t.Parallel()
+
+	_, server := newFakeVault(t)
+
+	secretIDFile := filepath.Join(t.TempDir(), "secret-id")
+
+	err := os.WriteFile(secretIDFile, []byte(fakeVaultSecretID+"\n"), 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile() = %q", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		vc      vaultConfig
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "token",
+			vc:      vaultConfig{Address: server.URL}, //nolint:exhaustruct
+			env:     map[string]string{"VAULT_TOKEN": fakeVaultToken},
+			wantErr: "",
+		},
+		{
+			name:    "address from environment",
+			vc:      vaultConfig{}, //nolint:exhaustruct
+			env:     map[string]string{"VAULT_ADDR": server.URL + "/", "VAULT_TOKEN": fakeVaultToken},
+			wantErr: "",
+		},
+		{
+			name:    "approle with secret ID from environment",
+			vc:      vaultConfig{Address: server.URL, RoleID: fakeVaultRoleID}, //nolint:exhaustruct
+			env:     map[string]string{"VAULT_SECRET_ID": fakeVaultSecretID},
+			wantErr: "",
+		},
+		{
+			name:    "approle with secret ID file",
+			vc:      vaultConfig{Address: server.URL, RoleID: fakeVaultRoleID, SecretIDFile: secretIDFile}, //nolint:exhaustruct
+			env:     map[string]string{"VAULT_SECRET_ID": "ignored"},
+			wantErr: "",
+		},
+		{
+			name:    "approle with wrong secret ID",
+			vc:      vaultConfig{Address: server.URL, RoleID: fakeVaultRoleID}, //nolint:exhaustruct
+			env:     map[string]string{"VAULT_SECRET_ID": "wrong"},
+			wantErr: `logging in to Vault with AppRole role ID "sunlight-role": POST auth/approle/login: 400 Bad Request: invalid role or secret ID`,
+		},
+		{
+			name:    "approle without secret ID",
+			vc:      vaultConfig{Address: server.URL, RoleID: fakeVaultRoleID}, //nolint:exhaustruct
+			env:     map[string]string{},
+			wantErr: "no Vault secret ID",
+		},
+		{
+			name:    "no token",
+			vc:      vaultConfig{Address: server.URL}, //nolint:exhaustruct
+			env:     map[string]string{},
+			wantErr: "no Vault token",
+		},
+		{
+			name:    "no address",
+			vc:      vaultConfig{}, //nolint:exhaustruct
+			env:     map[string]string{"VAULT_TOKEN": fakeVaultToken},
+			wantErr: "no Vault address",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			smc := secretManagerConfig{Backend: backendVault, Vault: tc.vc} //nolint:exhaustruct
+
+			s, err := newVaultStore(t.Context(), smc, func(key string) string { return tc.env[key] }, server.Client())
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("newVaultStore() = %v, but want error %q", err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("newVaultStore() = %q, but want success", err)
+			}
+
+			if s.token != fakeVaultToken {
+				t.Errorf("newVaultStore() has token %q, but want %q", s.token, fakeVaultToken)
+			}
+
+			_, _, err = s.Get(t.Context(), "real")
+			if err != nil {
+				t.Errorf("Get() = %q, but want success", err)
+			}
+		})
+	}
+}
+
+func TestVaultStoreGet(t *testing.T) {
+	t.Parallel()
+
+	_, server := newFakeVault(t)
+	s := newTestVaultStore(t, server)
+
+	for _, tc := range []struct {
+		id          string
+		want        []byte
+		wantVersion string
+		wantErr     error
+		wantErrText string
+	}{
+		{id: "real", want: []byte("hello world"), wantVersion: "1", wantErr: nil, wantErrText: ""},
+		{id: "rewritten", want: []byte("hello world"), wantVersion: "2", wantErr: nil, wantErrText: ""},
+		{id: "absent", want: nil, wantVersion: "", wantErr: errSecretNotFound, wantErrText: ""},
+		{id: "sealed", want: nil, wantVersion: "", wantErr: errTransient, wantErrText: "Vault is sealed"},
+		{id: "deleted", want: nil, wantVersion: "", wantErr: nil, wantErrText: `version 1 of secret "deleted" was deleted at 2025-01-02T03:04:05Z`},
+		{id: "garbled", want: nil, wantVersion: "1", wantErr: nil, wantErrText: `decoding field "seed" of secret "garbled" as base64`},
+	} {
+		t.Run(tc.id, func(t *testing.T) {
+			t.Parallel()
+
+			got, version, err := s.Get(t.Context(), tc.id)
+
+			switch {
+			case tc.wantErr == nil && tc.wantErrText == "" && err != nil:
+				t.Errorf("Get(%q) = %q, but want success", tc.id, err)
+			case tc.wantErr != nil && !errors.Is(err, tc.wantErr):
+				t.Errorf("Get(%q) = %v, but want error wrapping %q", tc.id, err, tc.wantErr)
+			case tc.wantErrText != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErrText)):
+				t.Errorf("Get(%q) = %v, but want error %q", tc.id, err, tc.wantErrText)
+			case tc.wantErr == nil && errors.Is(err, errSecretNotFound):
+				t.Errorf("Get(%q) = %q, which would let a new seed be created", tc.id, err)
+			case !bytes.Equal(got, tc.want) || version.ID != tc.wantVersion:
+				t.Errorf("Get(%q) = %q at version %q, but want %q at version %q", tc.id, got, version.ID, tc.want, tc.wantVersion)
+			case version.Source != server.URL || version.Replica:
+				t.Errorf("Get(%q) = version %+v, but want one from %s", tc.id, version, server.URL)
+			}
+		})
+	}
+
+	denied := newTestVaultStore(t, server)
+	denied.token = "hvs.wrong"
+
+	_, _, err := denied.Get(t.Context(), "real")
+	if err == nil || errors.Is(err, errSecretNotFound) || errors.Is(err, errTransient) || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Get() with the wrong token = %v, but want a permanent permission denied error", err)
+	}
+}
+
+func TestVaultStoreCreateIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	const hosts = 5
+
+	fv, server := newFakeVault(t)
+	s := newTestVaultStore(t, server)
+
+	var wg sync.WaitGroup
+	seeds := make([][]byte, hosts)
+	versions := make([]seedVersion, hosts)
+	errs := make([]error, hosts)
+
+	for i := range hosts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			seeds[i], versions[i], errs[i] = s.CreateIfAbsent(t.Context(), "test/shard1", newSeed())
+		}()
+	}
+
+	wg.Wait()
+
+	secret := fv.secret("test/shard1")
+	if len(secret.versions) != 1 {
+		t.Fatalf("CreateIfAbsent() wrote %d versions, but want 1", len(secret.versions))
+	}
+
+	stored, err := base64.StdEncoding.DecodeString(secret.versions[0])
+	if err != nil {
+		t.Fatalf("stored seed isn't base64: %q", err)
+	}
+
+	for i := range hosts {
+		if errs[i] != nil {
+			t.Errorf("host %d: CreateIfAbsent() = %q, but want success", i, errs[i])
+		} else if !bytes.Equal(seeds[i], stored) || versions[i].ID != "1" {
+			t.Errorf("host %d: CreateIfAbsent() = %x at version %q, but the stored seed is %x at version 1", i, seeds[i], versions[i].ID, stored)
+		}
+	}
+
+	// An existing seed is never replaced.
+	got, _, err := s.CreateIfAbsent(t.Context(), "real", newSeed())
+	if err != nil || !bytes.Equal(got, []byte("hello world")) {
+		t.Errorf("CreateIfAbsent(real) = %q, %v, but want the existing seed", got, err)
+	}
+}
+
+func TestVaultStorePin(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		id         string
+		version    string
+		wantErr    string
+		wantCustom map[string]string
+	}{
+		{id: "real", version: "1", wantErr: "", wantCustom: map[string]string{"owner": "ct", vaultPinnedKey: "1"}},
+		{id: "pinned", version: "1", wantErr: "", wantCustom: map[string]string{vaultPinnedKey: "1"}},
+		{id: "real", version: "2", wantErr: `secret "real" has current version 1, but the seed was read from version 2`, wantCustom: nil},
+		{id: "rewritten", version: "2", wantErr: "the seed has changed since it was created", wantCustom: nil},
+		{id: "changed", version: "2", wantErr: "the seed has changed since it was first used", wantCustom: nil},
+		{id: "absent", version: "1", wantErr: errSecretNotFound.Error(), wantCustom: nil},
+	} {
+		t.Run(tc.id+"@"+tc.version, func(t *testing.T) {
+			t.Parallel()
+
+			fv, server := newFakeVault(t)
+			s := newTestVaultStore(t, server)
+
+			version := seedVersion{ID: tc.version, Source: server.URL, Replica: false}
+
+			err := s.CheckPin(t.Context(), tc.id, version)
+			if err == nil && tc.id == "real" && fv.secret(tc.id).custom[vaultPinnedKey] != "" {
+				t.Errorf("CheckPin(%q) recorded a pinned version, but want nothing recorded", tc.id)
+			}
+
+			if (err != nil) != (tc.wantErr != "") {
+				t.Errorf("CheckPin(%q) = %v, but want error: %t", tc.id, err, tc.wantErr != "")
+			}
+
+			err = s.Pin(t.Context(), tc.id, version)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("Pin(%q) = %v, but want error %q", tc.id, err, tc.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Pin(%q) = %q, but want success", tc.id, err)
+			}
+
+			if got := fv.secret(tc.id).custom; !maps.Equal(got, tc.wantCustom) {
+				t.Errorf("Pin(%q) left custom metadata %v, but want %v", tc.id, got, tc.wantCustom)
+			}
+		})
+	}
+}
+
+func TestVaultStoreCheckSafety(t *testing.T) {
+	t.Parallel()
+
+	_, server := newFakeVault(t)
+	s := newTestVaultStore(t, server)
+
+	err := s.CheckSafety(t.Context(), "real", seedVersion{ID: "1", Source: server.URL, Replica: false})
+	if err != nil {
+		t.Errorf("CheckSafety(real) = %q, but want success", err)
+	}
+
+	err = s.CheckSafety(t.Context(), "expiring", seedVersion{ID: "1", Source: server.URL, Replica: false})
+	if err == nil || !strings.Contains(err.Error(), "deletes its versions after 768h0m0s") {
+		t.Errorf("CheckSafety(expiring) = %v, but want an error about delete_version_after", err)
+	}
+}
+
+func TestGetOrCreateSeedVault(t *testing.T) {
+	t.Parallel()
+
+	fv, server := newFakeVault(t)
+	backend := &vaultBackend{kv: newTestVaultStore(t, server)}
+
+	now := time.Date(2025, time.June, 15, 23, 59, 59, 0, time.UTC)
+	today := now.Format(time.DateOnly)
+	logs := []logConfig{
+		{Name: "test.tld/shard1", Inception: "2024-08-07", SecretID: "real"},    //nolint:exhaustruct
+		{Name: "test.tld/shard2", Inception: today, SecretID: "test/shard2"},    //nolint:exhaustruct
+		{Name: "test.tld/shard3", Inception: "2024-08-07", SecretID: "deleted"}, //nolint:exhaustruct
+	}
+	conf := &config{Logs: logs} //nolint:exhaustruct
+
+	fetched := backend.fetchAll(t.Context(), logs)
+
+	// Every log's seed is in the same KV engine.
+	store, err := backend.store(logs[0])
+	if err != nil {
+		t.Fatalf("store() = %q, but want success", err)
+	}
+
+	seed, _, err := getOrCreateSeed(t.Context(), "real", logs[0], conf, store, fetched["real"], now)
+	if err != nil || !bytes.Equal(seed, []byte("hello world")) {
+		t.Errorf("getOrCreateSeed(real) = %q, %v, but want the existing seed", seed, err)
+	}
+
+	seed, version, err := getOrCreateSeed(t.Context(), "test/shard2", logs[1], conf, store, fetched["test/shard2"], now)
+	if err != nil || len(seed) != seedLen {
+		t.Fatalf("getOrCreateSeed(test/shard2) = %x, %v, but want a new seed", seed, err)
+	}
+
+	if version.ID != "1" || fv.secret("test/shard2").custom[vaultPinnedKey] != "" {
+		t.Errorf("getOrCreateSeed(test/shard2) returned version %q and pinned %q, but want version 1 not yet pinned", version.ID, fv.secret("test/shard2").custom[vaultPinnedKey])
+	}
+
+	err = store.Pin(t.Context(), "test/shard2", version)
+	if err != nil || fv.secret("test/shard2").custom[vaultPinnedKey] != "1" {
+		t.Errorf("Pin(test/shard2) = %v and pinned %q, but want version 1 pinned", err, fv.secret("test/shard2").custom[vaultPinnedKey])
+	}
+
+	_, _, err = getOrCreateSeed(t.Context(), "deleted", logs[2], conf, store, fetched["deleted"], now)
+	if err == nil || !strings.Contains(err.Error(), "was deleted") {
+		t.Errorf("getOrCreateSeed(deleted) = %v, but want an error about the deleted version", err)
+	}
+
+	if len(fv.secret("deleted").versions) != 1 {
+		t.Error("getOrCreateSeed(deleted) wrote a new version over the deleted one")
+	}
+}